@@ -2,7 +2,10 @@ package main
 
 import (
 	"edigo/pkg/ui"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
@@ -11,25 +14,38 @@ import (
 )
 
 func main() {
+	profile := flag.String("profile", "", "key bindings: default, emacs or vim (overrides the keymap file)")
+	backup := flag.String("backup", "", `back up files before saving: "~" next to them, or a directory`)
+	flag.Parse()
 
-	if len(os.Args) < 2 {
-		fmt.Println("Please provide the file path as an argument.")
+	if flag.NArg() < 1 {
+		fmt.Println("Please provide one or more file paths as arguments.")
 		os.Exit(1)
 	}
 
-	filePath := os.Args[1]
+	filePath := flag.Arg(0)
 	content, err := ioutil.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A new file, it is created on the first save.
+		content, err = nil, nil
+	}
 	if err != nil {
 		log.Fatalf("Error reading file: %v\n", err)
 	}
 
-	model := ui.NewUIModel(string(content), filePath)
+	model := ui.NewUIModel(string(content), filePath, *profile)
+	model.Editor.Backup = *backup
+	for _, path := range flag.Args()[1:] {
+		if _, err := model.Editor.AddBuffer(path); err != nil {
+			log.Fatalf("Error reading file: %v\n", err)
+		}
+	}
 
 	go func() {
 		model.Editor.Network.ListenForBroadcasts()
 	}()
 
-	p := tea.NewProgram(model)
+	p := tea.NewProgram(model, tea.WithMouseCellMotion())
 	if err := p.Start(); err != nil {
 		log.Fatalf("Error starting the program: %v\n", err)
 	}
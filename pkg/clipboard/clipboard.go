@@ -0,0 +1,60 @@
+package clipboard
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// register keeps the last copied text for when the system clipboard cannot
+// be read, e.g. over SSH where OSC52 only lets us write.
+var (
+	register   string
+	registerMu sync.Mutex
+)
+
+// Write puts text on the system clipboard. Over SSH, or when no clipboard
+// tool is available, the text is sent to the terminal as an OSC52 sequence.
+func Write(text string) error {
+	registerMu.Lock()
+	register = text
+	registerMu.Unlock()
+
+	if !isRemote() {
+		if err := clipboard.WriteAll(text); err == nil {
+			return nil
+		}
+	}
+	return writeOSC52(text)
+}
+
+// Read returns the system clipboard, or the last text we copied if the
+// system clipboard is not reachable.
+func Read() string {
+	if !isRemote() {
+		if text, err := clipboard.ReadAll(); err == nil {
+			return text
+		}
+	}
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	return register
+}
+
+func writeOSC52(text string) error {
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	_, err := seq.WriteTo(os.Stderr)
+	return err
+}
+
+func isRemote() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
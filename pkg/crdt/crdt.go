@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"math/rand"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -91,6 +92,10 @@ func (rga *RGA) LocalDelete() Operation {
 }
 
 func (rga *RGA) RemoteInsert(op Operation) {
+	// An edit may reach us twice, in a snapshot and relayed after it.
+	if rga.IndexOfID(op.ID) >= 0 {
+		return
+	}
 	newElement := Element{ID: op.ID, Character: op.Character, Tombstone: false}
 
 	if op.Position >= len(rga.Elements) {
@@ -222,6 +227,97 @@ func (rga *RGA) ConvertCursior(index int) int {
     return index - count
 }
 
+// ElementIndex is the inverse of ConvertCursior: it returns the index into
+// Elements of the visible offset, or len(Elements) past the last character.
+func (rga *RGA) ElementIndex(visible int) int {
+	for i, elem := range rga.Elements {
+		if elem.Tombstone {
+			continue
+		}
+		if visible == 0 {
+			return i
+		}
+		visible--
+	}
+	return len(rga.Elements)
+}
+
+// LocalInsertText inserts text at the cursor in one go and returns the
+// operations to replay it elsewhere.
+// LocalInsertText inserts text at the cursor in one go, a long paste would
+// take ages character by character.
+func (rga *RGA) LocalInsertText(text string) []Operation {
+	chars := []rune(text)
+	ops := make([]Operation, 0, len(chars))
+	if len(chars) == 0 {
+		return ops
+	}
+
+	at := min(rga.CursorPosition, len(rga.Elements))
+	inserted := make([]Element, len(chars))
+	for i, char := range chars {
+		id := rga.generateID()
+		inserted[i] = Element{ID: id, Character: char, Tombstone: false}
+		ops = append(ops, Operation{Type: Insert, ID: id, Character: char, Position: at + i})
+	}
+	rga.Elements = slices.Insert(rga.Elements, at, inserted...)
+
+	rga.CursorPosition = at + len(chars) - 1
+	rga.MoveCursorRight()
+
+	rga.updateChecksum()
+	return ops
+}
+
+// LocalDeleteRange tombstones the visible characters from start up to end
+// and leaves the cursor where the range began.
+func (rga *RGA) LocalDeleteRange(start int, end int) []Operation {
+	ops := []Operation{}
+	visible := 0
+	for i := range rga.Elements {
+		if rga.Elements[i].Tombstone {
+			continue
+		}
+		if visible >= start && visible < end {
+			rga.Elements[i].Tombstone = true
+			ops = append(ops, Operation{Type: Delete, ID: rga.Elements[i].ID, Position: i})
+		}
+		visible++
+	}
+	rga.CursorPosition = rga.ElementIndex(start)
+
+	rga.updateChecksum()
+	return ops
+}
+
+// IndexOfID returns the index into Elements of the element with the given
+// ID, or -1. Tombstoned elements keep their place, so the index stays
+// meaningful after the character was deleted.
+func (rga *RGA) IndexOfID(id string) int {
+	for i, elem := range rga.Elements {
+		if elem.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Copy returns a copy that shares nothing with rga, to send or render it
+// while others keep editing. The caller holds InsertM.
+func (rga *RGA) Copy() *RGA {
+	copied := *rga
+	copied.Elements = append([]Element(nil), rga.Elements...)
+	copied.RemoteCursors = make(map[string]int, len(rga.RemoteCursors))
+	for id, position := range rga.RemoteCursors {
+		copied.RemoteCursors[id] = position
+	}
+	return &copied
+}
+
+func (rga *RGA) SetCursor(visible int) {
+	rga.CursorPosition = rga.ElementIndex(visible)
+}
+
 // Add this method to update the checksum
 func (rga *RGA) updateChecksum() {
 	data := []byte(rga.GetText())
@@ -0,0 +1,201 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Modified reports whether the open file differs from what was last loaded
+// or saved.
+func (e *Editor) Modified() bool {
+	return e.RGA.Checksum != e.saved
+}
+
+// ModifiedBuffers lists the files held in memory with unsaved changes,
+// including files guests edit that have no tab.
+func (e *Editor) ModifiedBuffers() []string {
+	modified := []string{}
+	if e.Modified() {
+		modified = append(modified, e.Document)
+	}
+	e.docMu.Lock()
+	defer e.docMu.Unlock()
+	for path, doc := range e.documents {
+		if doc.RGA.Checksum != doc.Saved {
+			modified = append(modified, path)
+		}
+	}
+	sort.Strings(modified)
+	return modified
+}
+
+// BufferModified reports whether a buffer has unsaved changes.
+func (e *Editor) BufferModified(path string) bool {
+	if path == e.Document {
+		return e.Modified()
+	}
+	e.docMu.Lock()
+	defer e.docMu.Unlock()
+	doc, exists := e.documents[path]
+	return exists && doc.RGA.Checksum != doc.Saved
+}
+
+func (e *Editor) isBuffer(path string) bool {
+	for _, buffer := range e.Buffers {
+		if buffer == path {
+			return true
+		}
+	}
+	return false
+}
+
+// markSaved records that a file now matches the disk, on the host's word
+// for guests.
+func (e *Editor) markSaved(path string) {
+	if path == e.Document {
+		e.saved = e.RGA.Checksum
+		return
+	}
+	e.docMu.Lock()
+	if doc, exists := e.documents[path]; exists {
+		doc.Saved = doc.RGA.Checksum
+	}
+	e.docMu.Unlock()
+}
+
+// Save writes a file held in memory to disk. It leaves the file alone when
+// another program changed it since we loaded it, see ErrChangedOnDisk.
+func (e *Editor) Save(path string) error {
+	return e.save(path, false)
+}
+
+// Overwrite saves a file even if it changed on disk.
+func (e *Editor) Overwrite(path string) error {
+	return e.save(path, true)
+}
+
+func (e *Editor) save(path string, force bool) error {
+	var file string
+	var data []byte
+	var err error
+	if path == e.Document {
+		file = e.FilePath
+		data, err = e.EncodedDocument()
+	} else {
+		e.docMu.Lock()
+		doc, exists := e.documents[path]
+		e.docMu.Unlock()
+		if !exists {
+			return fmt.Errorf("%s is not open", path)
+		}
+		file = e.documentFilePath(path)
+		data, err = doc.Format.Encode(doc.RGA.GetTextWithOutTomestone())
+	}
+	if err != nil {
+		return fmt.Errorf("%s not saved: %v", path, err)
+	}
+	if err := e.writeFile(path, file, data, force); err != nil {
+		return err
+	}
+	e.markSaved(path)
+	return nil
+}
+
+// bufferPath turns a file path into a session path, relative to the
+// session root.
+func (e *Editor) bufferPath(file string) (string, error) {
+	absPath, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(e.SessionRoot, absPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// AddBuffer loads a file into a new tab without switching to it.
+func (e *Editor) AddBuffer(file string) (string, error) {
+	if e.Network.Host != nil {
+		return "", fmt.Errorf("Guests open the files the host shares")
+	}
+	path, err := e.bufferPath(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := e.documentRGA(path); err != nil {
+		return "", err
+	}
+	if !e.isBuffer(path) {
+		e.Buffers = append(e.Buffers, path)
+	}
+	return path, nil
+}
+
+// OpenFile opens a file in a tab of its own, or switches to its tab.
+func (e *Editor) OpenFile(file string) {
+	path, err := e.AddBuffer(file)
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+	e.OpenDocument(path)
+}
+
+// NextBuffer switches to the tab delta places on, wrapping around.
+func (e *Editor) NextBuffer(delta int) {
+	if len(e.Buffers) < 2 {
+		return
+	}
+	current := 0
+	for i, path := range e.Buffers {
+		if path == e.Document {
+			current = i
+		}
+	}
+	next := ((current+delta)%len(e.Buffers) + len(e.Buffers)) % len(e.Buffers)
+	e.OpenDocument(e.Buffers[next])
+}
+
+// CloseBuffer closes the tab of the open file and shows its neighbour. A
+// file guests may still edit stays loaded in the background.
+func (e *Editor) CloseBuffer() {
+	if len(e.Buffers) < 2 {
+		e.Error = "Cannot close the last file"
+		return
+	}
+	closed := e.Document
+	buffers := []string{}
+	next := ""
+	for i, path := range e.Buffers {
+		if path == closed {
+			next = e.Buffers[max(i-1, 0)]
+			if i == 0 {
+				next = e.Buffers[1]
+			}
+			continue
+		}
+		buffers = append(buffers, path)
+	}
+	e.Buffers = buffers
+	e.OpenDocument(next)
+	if e.Document == closed {
+		// Guests switch once the host sends the file and keep the closed
+		// one parked.
+		return
+	}
+
+	if !e.Network.IsHost || !e.IsShared(closed) {
+		e.docMu.Lock()
+		delete(e.documents, closed)
+		e.docMu.Unlock()
+	}
+}
+
+// private reports whether the open file is one the host did not share.
+// Nothing that happens in it is sent to guests.
+func (e *Editor) private() bool {
+	return e.Network.IsHost && !e.IsShared(e.Document)
+}
@@ -0,0 +1,81 @@
+package editor
+
+import (
+	"edigo/pkg/network"
+	"net"
+	"strings"
+	"time"
+)
+
+type ChatEntry struct {
+	Sender     string
+	Username   string
+	ThemeIndex int
+	Text       string
+	Time       time.Time
+}
+
+func (e *Editor) SendChat(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	msg := network.Message{Type: network.ChatMessage, Sender: e.Network.ID, Text: text}
+	e.appendChat(msg)
+	e.sendMessage(msg)
+}
+
+// ChatHistory returns a copy of the chat log that is safe to render.
+func (e *Editor) ChatHistory() []ChatEntry {
+	e.chatMu.Lock()
+	defer e.chatMu.Unlock()
+	return append([]ChatEntry(nil), e.chat...)
+}
+
+func (e *Editor) UnreadChat() int {
+	e.chatMu.Lock()
+	defer e.chatMu.Unlock()
+	return e.unreadChat
+}
+
+func (e *Editor) MarkChatRead() {
+	e.chatMu.Lock()
+	e.unreadChat = 0
+	e.chatMu.Unlock()
+}
+
+// receiveChat logs a message of a peer. The history a guest gets when it
+// joins counts as read.
+func (e *Editor) receiveChat(msg network.Message) {
+	e.appendChat(msg)
+	if msg.History {
+		return
+	}
+	e.chatMu.Lock()
+	e.unreadChat++
+	e.chatMu.Unlock()
+}
+
+func (e *Editor) appendChat(msg network.Message) {
+	entry := ChatEntry{Sender: msg.Sender, Text: msg.Text, Time: time.Now()}
+	if msg.Sender == e.Network.ID {
+		entry.Username = e.LocalCursor.Username
+		entry.ThemeIndex = e.LocalCursor.ThemeIndex
+	} else {
+		user := e.remoteUser(msg.Sender)
+		entry.Username = user.Username
+		entry.ThemeIndex = user.ThemeIndex
+	}
+
+	e.chatMu.Lock()
+	e.chat = append(e.chat, entry)
+	e.chatMu.Unlock()
+}
+
+// sendChatHistory replays the chat log to a guest that joined late.
+func (e *Editor) sendChatHistory(conn net.Conn) {
+	for _, entry := range e.ChatHistory() {
+		e.Network.SendMessage(network.Message{Type: network.ChatMessage, Sender: entry.Sender, Text: entry.Text, History: true}, conn)
+	}
+}
@@ -0,0 +1,215 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"slices"
+	"sort"
+)
+
+// caret is a cursor besides the local one. Its head is an anchor like the
+// one of the selection, the ID of the element just before it, so edits of
+// collaborators do not knock it out of place.
+type caret struct {
+	head      string
+	selection Selection
+	goal      goalColumn
+}
+
+// multiCursor holds the extra cursors. While a command runs at each of
+// them, the operations are collected to go out as one batch.
+type multiCursor struct {
+	carets  []caret
+	running bool
+	batch   []crdt.Operation
+}
+
+// ExtraCursors is the number of cursors besides the local one.
+func (e *Editor) ExtraCursors() int {
+	return len(e.cursors.carets)
+}
+
+// DropCursors goes back to the local cursor alone.
+func (e *Editor) DropCursors() {
+	e.cursors.carets = nil
+}
+
+func (e *Editor) saveCaret() caret {
+	return caret{head: e.anchorAt(e.RGA.CursorPosition), selection: e.Selection, goal: e.goal}
+}
+
+func (e *Editor) restoreCaret(c caret) {
+	e.RGA.SetCursor(e.anchorOffset(c.head))
+	e.Selection = c.selection
+	e.goal = c.goal
+}
+
+// caretAt is a cursor at end selecting back to start.
+func (e *Editor) caretAt(start int, end int) caret {
+	c := caret{head: e.anchorAt(e.RGA.ElementIndex(end))}
+	if start != end {
+		c.selection = Selection{Anchor: e.anchorAt(e.RGA.ElementIndex(start)), Active: true}
+	}
+	return c
+}
+
+// caretRange returns the visible offsets a caret selects, start before end.
+func (e *Editor) caretRange(c caret) (int, int, bool) {
+	if !c.selection.Active {
+		return 0, 0, false
+	}
+	anchor := e.anchorOffset(c.selection.Anchor)
+	head := e.anchorOffset(c.head)
+	if anchor == head {
+		return 0, 0, false
+	}
+	return min(anchor, head), max(anchor, head), true
+}
+
+// forEachCursor runs a command at the local cursor and at every extra one.
+// The edits of all of them are sent as a single batch, and cursors that end
+// up in the same place merge.
+func (e *Editor) forEachCursor(run func()) {
+	if len(e.cursors.carets) == 0 || e.cursors.running {
+		run()
+		return
+	}
+
+	all := append([]caret{e.saveCaret()}, e.cursors.carets...)
+	e.cursors.running = true
+	for i := range all {
+		e.restoreCaret(all[i])
+		run()
+		all[i] = e.saveCaret()
+	}
+	e.cursors.running = false
+	ops := e.cursors.batch
+	e.cursors.batch = nil
+
+	e.restoreCaret(all[0])
+	seen := map[int]bool{e.cursorOffset(): true}
+	e.cursors.carets = nil
+	for _, c := range all[1:] {
+		offset := e.anchorOffset(c.head)
+		if !seen[offset] {
+			seen[offset] = true
+			e.cursors.carets = append(e.cursors.carets, c)
+		}
+	}
+
+	e.sendBatch(ops)
+	e.StopFollowing()
+	e.syncLocalCursor()
+	e.ScrollToCursor()
+}
+
+func (e *Editor) AddCursorAbove() {
+	e.addCursorVertical(-1)
+}
+
+func (e *Editor) AddCursorBelow() {
+	e.addCursorVertical(1)
+}
+
+// addCursorVertical adds a cursor on the line past the outermost cursor in
+// that direction, in the column of the local cursor.
+func (e *Editor) addCursorVertical(delta int) {
+	lines := newLineIndex(e.RGA.GetText())
+	line, col := lines.position(e.cursorOffset())
+	cells := displayWidth(lines.line(line)[:col], e.Indent.TabWidth)
+	if e.goal.set {
+		cells = e.goal.cells
+	}
+
+	edge := line
+	for _, c := range e.cursors.carets {
+		caretLine, _ := lines.position(e.anchorOffset(c.head))
+		if (caretLine-edge)*delta > 0 {
+			edge = caretLine
+		}
+	}
+	target := edge + delta
+	if target < 0 || target >= lines.lineCount() {
+		return
+	}
+	offset := lines.offset(target, columnAtWidth(lines.line(target), cells, e.Indent.TabWidth))
+	e.cursors.carets = append(e.cursors.carets, e.caretAt(offset, offset))
+}
+
+// AddCursorAtNextMatch selects the word at the cursor. With a selection it
+// moves on to the next occurrence of the selected text, leaving a cursor
+// behind on the current one.
+func (e *Editor) AddCursorAtNextMatch() {
+	text := []rune(e.RGA.GetText())
+	start, end, ok := e.SelectionRange()
+	if !ok {
+		if start, end := newLineIndex(string(text)).wordAt(e.cursorOffset()); start < end {
+			e.selectRange(start, end)
+		}
+		return
+	}
+
+	taken := map[int]bool{start: true}
+	for _, c := range e.cursors.carets {
+		if from, _, ok := e.caretRange(c); ok {
+			taken[from] = true
+		}
+	}
+	matches := occurrences(text, text[start:end])
+	// Search on after the selection and wrap around to the top.
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i] >= end && matches[j] < end })
+	for _, match := range matches {
+		if taken[match] {
+			continue
+		}
+		e.cursors.carets = append(e.cursors.carets, e.saveCaret())
+		e.selectRange(match, match+end-start)
+		return
+	}
+}
+
+// SelectAllMatches puts a cursor on every occurrence of the selection, or
+// of the word at the cursor.
+func (e *Editor) SelectAllMatches() {
+	text := []rune(e.RGA.GetText())
+	start, end, ok := e.SelectionRange()
+	if !ok {
+		start, end = newLineIndex(string(text)).wordAt(e.cursorOffset())
+		if start == end {
+			return
+		}
+	}
+
+	e.cursors.carets = nil
+	for _, match := range occurrences(text, text[start:end]) {
+		if match != start {
+			e.cursors.carets = append(e.cursors.carets, e.caretAt(match, match+end-start))
+		}
+	}
+	e.selectRange(start, end)
+}
+
+// selectRange selects from start to end with the cursor at the end.
+func (e *Editor) selectRange(start int, end int) {
+	e.ClearSelection()
+	e.RGA.SetCursor(start)
+	e.startSelection()
+	e.RGA.SetCursor(end)
+	e.updateLocalCursor()
+}
+
+// occurrences returns where needle starts in text, without overlaps.
+func occurrences(text []rune, needle []rune) []int {
+	found := []int{}
+	if len(needle) == 0 {
+		return found
+	}
+	for i := 0; i+len(needle) <= len(text); {
+		if slices.Equal(text[i:i+len(needle)], needle) {
+			found = append(found, i)
+			i += len(needle)
+		} else {
+			i++
+		}
+	}
+	return found
+}
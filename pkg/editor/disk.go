@@ -0,0 +1,160 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrChangedOnDisk is returned by Save when another program wrote the file
+// after we loaded it. Overwrite saves anyway.
+var ErrChangedOnDisk = errors.New("changed on disk since it was loaded")
+
+// diskStamp is what we know of a file on disk, to tell when someone else
+// wrote it.
+type diskStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(file string) diskStamp {
+	info, err := os.Stat(file)
+	if err != nil {
+		return diskStamp{}
+	}
+	return diskStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// changedSince reports whether the file was written after the stamp was
+// taken. A file deleted in the meantime is simply created again.
+func (s diskStamp) changedSince(loaded diskStamp) bool {
+	if !s.exists {
+		return false
+	}
+	return !loaded.exists || s.size != loaded.size || !s.modTime.Equal(loaded.modTime)
+}
+
+// recordDisk remembers the state of a session file as we loaded or saved
+// it. The caller holds docMu.
+func (e *Editor) recordDisk(path string, file string) {
+	e.disk[path] = statFile(file)
+}
+
+// writeFile saves the contents of a session file.
+func (e *Editor) writeFile(path string, file string, data []byte, force bool) error {
+	e.docMu.Lock()
+	loaded, known := e.disk[path]
+	e.docMu.Unlock()
+	if !force && known && statFile(file).changedSince(loaded) {
+		return fmt.Errorf("%s %w", path, ErrChangedOnDisk)
+	}
+
+	if err := writeAtomic(file, data, 0644, e.Backup); err != nil {
+		return err
+	}
+	e.docMu.Lock()
+	e.recordDisk(path, file)
+	e.docMu.Unlock()
+	return nil
+}
+
+// writeAtomic replaces a file without ever leaving it half written: the
+// data goes to a temporary file next to it, which is synced and renamed
+// over the file. The file keeps its mode and, where we may, its owner, new
+// files get mode. Symbolic links stay links, the file they point to is
+// replaced.
+func writeAtomic(file string, data []byte, mode os.FileMode, backup string) error {
+	if target, err := filepath.EvalSymlinks(file); err == nil {
+		file = target
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return replaceFile(file, data, mode, nil)
+	}
+	mode = info.Mode().Perm()
+	if backup != "" {
+		if err := writeBackup(file, backup, mode); err != nil {
+			return fmt.Errorf("Error writing backup: %v", err)
+		}
+	}
+	return replaceFile(file, data, mode, info)
+}
+
+// replaceFile renames a synced temporary file with data and mode over file.
+// A symbolic link at file is replaced, not followed. With owner set, the
+// new file keeps its owner where we may.
+func replaceFile(file string, data []byte, mode os.FileMode, owner os.FileInfo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fail(err)
+	}
+	if owner != nil {
+		// Only root may give a file away, others keep their own files.
+		keepOwner(tmp, owner)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, file); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	syncDir(filepath.Dir(file))
+	return nil
+}
+
+// writeBackup copies a file before it is replaced. A backup of "~" goes
+// next to the file, any other value is a directory that gets the backups
+// of every file, named after their full path like Vim does. Backups are as
+// private as the file.
+func writeBackup(file string, backup string, mode os.FileMode) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	name := file + "~"
+	if backup != "~" {
+		if err := os.MkdirAll(backup, 0755); err != nil {
+			return err
+		}
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		name = filepath.Join(backup, strings.ReplaceAll(filepath.ToSlash(absPath), "/", "%")+"~")
+	}
+	// The backup gets the mode of the file even if an older backup is there,
+	// and a link planted in its place is replaced rather than written through.
+	return replaceFile(name, data, mode, nil)
+}
+
+// syncDir makes the rename of a file in dir durable. Not every system can
+// sync a directory, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
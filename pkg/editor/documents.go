@@ -0,0 +1,315 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"edigo/pkg/editorconfig"
+	"edigo/pkg/ignore"
+	"edigo/pkg/network"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const maxProjectFiles = 500
+
+// Document is a session file that is loaded but not shown in the editor.
+type Document struct {
+	Path      string
+	RGA       *crdt.RGA
+	ScrollTop int
+	Format    editorconfig.Settings
+	Saved     uint32 // checksum when last loaded or saved
+}
+
+// StartHosting shares the session root, the directory of the first file.
+// Guests start in the current file, which is shared along with the files
+// the host shared before.
+func (e *Editor) StartHosting() {
+	e.Network.HostDocument = e.Document
+	e.Network.HostFilePath = e.FilePath
+	e.Network.HostFileExt = e.FileExt
+
+	e.docMu.Lock()
+	if !e.isShared(e.Document) {
+		e.SharedPaths = append(e.SharedPaths, e.Document)
+		sort.Strings(e.SharedPaths)
+	}
+	e.docMu.Unlock()
+}
+
+// ProjectFiles lists the files below the session root that the host may share.
+func (e *Editor) ProjectFiles() []string {
+	return e.projectFiles(maxProjectFiles)
+}
+
+// projectFiles walks the session root, leaving out hidden directories and
+// whatever .gitignore excludes.
+func (e *Editor) projectFiles(limit int) []string {
+	root := e.SessionRoot
+	if root == "" {
+		root = filepath.Dir(e.FilePath)
+	}
+	ignored := ignore.New(root)
+
+	files := []string{}
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") || ignored.Ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			ignored.AddDir(rel)
+			return nil
+		}
+		if ignored.Ignored(rel, false) {
+			return nil
+		}
+		if len(files) >= limit {
+			return filepath.SkipAll
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func (e *Editor) IsShared(path string) bool {
+	e.docMu.Lock()
+	defer e.docMu.Unlock()
+	return e.isShared(path)
+}
+
+func (e *Editor) isShared(path string) bool {
+	for _, shared := range e.SharedPaths {
+		if shared == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Editor) SharedFiles() []string {
+	e.docMu.Lock()
+	defer e.docMu.Unlock()
+	return append([]string(nil), e.SharedPaths...)
+}
+
+// ToggleShared adds or removes a path from the files guests may open.
+// The file guests start in stays shared.
+func (e *Editor) ToggleShared(path string) {
+	if e.Network.IsHost && path == e.Network.HostDocument {
+		e.Error = "Guests start in " + path + ", it stays shared"
+		return
+	}
+
+	e.docMu.Lock()
+	shared := []string{}
+	found := false
+	for _, p := range e.SharedPaths {
+		if p == path {
+			found = true
+			continue
+		}
+		shared = append(shared, p)
+	}
+	if !found {
+		shared = append(shared, path)
+		sort.Strings(shared)
+		e.Error = "Sharing " + path
+	} else {
+		e.Error = "Stopped sharing " + path
+	}
+	e.SharedPaths = shared
+	e.docMu.Unlock()
+
+	e.sendMessage(network.Message{Type: network.FileListMessage, Paths: e.SharedFiles()})
+}
+
+// OpenDocument shows another shared file. Guests ask the host for the
+// current state of the file, the host loads it from disk if needed.
+func (e *Editor) OpenDocument(path string) {
+	e.StopFollowing()
+	e.openDocument(path)
+}
+
+func (e *Editor) openDocument(path string) {
+	if path == e.Document {
+		return
+	}
+	if e.Network.Host != nil {
+		if !e.IsShared(path) {
+			e.Error = path + " is not shared in this session"
+			return
+		}
+		e.sendMessage(network.Message{Type: network.OpenDocumentMessage, Document: path})
+		e.Error = "Opening " + path + "..."
+		return
+	}
+
+	rga, err := e.documentRGA(path)
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+	e.switchDocument(path, rga)
+}
+
+// documentRGA returns the replica of a session file, loading it from the
+// session root the first time it is requested.
+func (e *Editor) documentRGA(path string) (*crdt.RGA, error) {
+	if path == e.Document {
+		return e.RGA, nil
+	}
+
+	e.docMu.Lock()
+	defer e.docMu.Unlock()
+
+	if doc, exists := e.documents[path]; exists {
+		return doc.RGA, nil
+	}
+
+	// A file that does not exist yet opens empty, saving creates it.
+	data, err := os.ReadFile(e.documentFilePath(path))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("Error reading %s: %v", path, err)
+	}
+	content, format := loadFormat(e.documentFilePath(path), data)
+	rga := crdt.NewRGA(e.RGA.Site)
+	for _, char := range content {
+		rga.LocalInsert(char)
+	}
+	rga.CursorPosition = 0
+	e.documents[path] = &Document{Path: path, RGA: rga, Format: format, Saved: rga.Checksum}
+	e.recordDisk(path, e.documentFilePath(path))
+	return rga, nil
+}
+
+// switchDocument parks the active document and makes path the active one.
+func (e *Editor) switchDocument(path string, rga *crdt.RGA) {
+	crdt.InsertM.Lock()
+	e.docMu.Lock()
+	if e.Document != "" {
+		e.documents[e.Document] = &Document{Path: e.Document, RGA: e.RGA, ScrollTop: e.ScrollTop, Format: e.Format, Saved: e.saved}
+	}
+	scrollTop := 0
+	format := e.remoteFormats[path]
+	saved := rga.Checksum
+	if doc, exists := e.documents[path]; exists {
+		scrollTop = doc.ScrollTop
+		format = doc.Format
+		if doc.RGA == rga {
+			saved = doc.Saved
+		}
+		delete(e.documents, path)
+	}
+	e.RGA = rga
+	e.Document = path
+	e.saved = saved
+	e.docMu.Unlock()
+	crdt.InsertM.Unlock()
+
+	if !e.isBuffer(path) {
+		e.Buffers = append(e.Buffers, path)
+	}
+
+	e.FilePath = e.documentDisplayPath(path)
+	e.FileExt = filepath.Ext(path)
+	e.Format = format
+	e.setSyntax(e.FileExt)
+	e.ScrollTop = scrollTop
+	e.ScrollLeft = 0
+	e.ClearSelection()
+	e.DropCursors()
+	e.Visual = Visual{}
+	e.goal = goalColumn{}
+	e.syncLocalCursor()
+	e.sentView = network.ViewRange{}
+	e.SendViewportUpdate()
+	if e.Following != "" {
+		e.followCursor()
+	}
+	e.takePendingJump()
+}
+
+func (e *Editor) documentFilePath(path string) string {
+	return filepath.Join(e.SessionRoot, filepath.FromSlash(path))
+}
+
+// applyDocumentOperation applies an edit to a session file that is not the
+// open one. It reports false when we do not hold that file.
+func (e *Editor) applyDocumentOperation(path string, op crdt.Operation) bool {
+	e.docMu.Lock()
+	doc, exists := e.documents[path]
+	e.docMu.Unlock()
+	if !exists {
+		return false
+	}
+	if op.Type != crdt.Move {
+		doc.RGA.ApplyOperation(op)
+	}
+	return true
+}
+
+func (e *Editor) handleOpenDocument(conn net.Conn, path string) {
+	if !e.IsShared(path) {
+		e.Network.SendMessage(network.Message{Type: network.FileListMessage, Paths: e.SharedFiles()}, conn)
+		return
+	}
+	// Edits to the file are relayed under RelayM, so the guest gets each of
+	// them either in the snapshot or after it.
+	e.Network.RelayM.Lock()
+	defer e.Network.RelayM.Unlock()
+
+	rga, err := e.documentRGA(path)
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+
+	e.sendFormat(path, conn)
+
+	crdt.InsertM.Lock()
+	snapshot := rga.Copy()
+	crdt.InsertM.Unlock()
+
+	e.Network.SendMessage(network.Message{Type: network.SnapshotMessage, Document: path, Snapshot: snapshot}, conn)
+}
+
+func (e *Editor) receiveFileList(paths []string) {
+	e.docMu.Lock()
+	e.SharedPaths = paths
+	stillShared := e.isShared(e.Document)
+	e.docMu.Unlock()
+
+	if !stillShared && len(paths) > 0 {
+		e.Error = e.Document + " is no longer shared"
+		e.openDocument(paths[0])
+	}
+}
+
+func (e *Editor) receiveSnapshot(path string, snapshot *crdt.RGA) {
+	if snapshot == nil || !snapshot.VerifyIntegrity() {
+		e.Error = "Integrity check failed for " + path
+		return
+	}
+	snapshot.Site = e.RGA.Site
+	snapshot.CursorPosition = 0
+	snapshot.RemoteCursors = make(map[string]int)
+	e.switchDocument(path, snapshot)
+}
@@ -0,0 +1,269 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"strings"
+)
+
+// DeleteCharacterAfterCursor deletes the selection or the character under
+// the cursor.
+func (e *Editor) DeleteCharacterAfterCursor() {
+	if e.DeleteSelection() {
+		return
+	}
+	offset := e.cursorOffset()
+	if offset >= len([]rune(e.RGA.GetText())) {
+		return
+	}
+	e.sendBatch(e.RGA.LocalDeleteRange(offset, offset+1))
+	e.updateLocalCursor()
+}
+
+// DeleteWordBeforeCursor deletes the selection or back to the start of the
+// previous word.
+func (e *Editor) DeleteWordBeforeCursor() {
+	if e.DeleteSelection() {
+		return
+	}
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	e.deleteRange(lines.wordLeft(offset), offset)
+}
+
+// DeleteWordAfterCursor deletes the selection or up to the end of the next word.
+func (e *Editor) DeleteWordAfterCursor() {
+	if e.DeleteSelection() {
+		return
+	}
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	e.deleteRange(offset, lines.wordRight(offset))
+}
+
+// DeleteLines removes the lines the cursor or selection touches, together
+// with their line break.
+func (e *Editor) DeleteLines() {
+	lines := newLineIndex(e.RGA.GetText())
+	first, last := e.lineRange(lines)
+	_, col := lines.position(e.cursorOffset())
+
+	start, end := lines.lineStart(first), lines.lineStart(last+1)
+	if last+1 >= lines.lineCount() {
+		// The last line has no break of its own, take the one before it.
+		end = len(lines.text)
+		if first > 0 {
+			start = lines.lineEnd(first - 1)
+		}
+	}
+	e.ClearSelection()
+	ops := e.RGA.LocalDeleteRange(start, end)
+	e.sendBatch(ops)
+
+	lines = newLineIndex(e.RGA.GetText())
+	e.RGA.SetCursor(lines.offset(first, col))
+	e.updateLocalCursor()
+}
+
+// DuplicateLines copies the lines the cursor or selection touches below
+// themselves and moves the cursor onto the copy.
+func (e *Editor) DuplicateLines() {
+	lines := newLineIndex(e.RGA.GetText())
+	first, last := e.lineRange(lines)
+	block := string(lines.text[lines.lineStart(first):lines.lineEnd(last)])
+	line, col := lines.position(e.cursorOffset())
+
+	e.ClearSelection()
+	ops := e.insertAt(lines.lineEnd(last), "\n"+block)
+	e.sendBatch(ops)
+
+	lines = newLineIndex(e.RGA.GetText())
+	e.RGA.SetCursor(lines.offset(line+last-first+1, col))
+	e.updateLocalCursor()
+}
+
+// MoveLinesUp swaps the touched lines with the line above them. Only the
+// neighbouring line is deleted and reinserted, the moved lines keep their
+// identity for collaborators.
+func (e *Editor) MoveLinesUp() {
+	lines := newLineIndex(e.RGA.GetText())
+	first, last := e.lineRange(lines)
+	if first == 0 {
+		return
+	}
+	restore := e.saveSelection(lines)
+
+	above := string(lines.line(first - 1))
+	aboveStart := lines.lineStart(first - 1)
+	length := len(lines.line(first-1)) + 1
+	ops := e.RGA.LocalDeleteRange(aboveStart, aboveStart+length)
+	ops = append(ops, e.insertAt(lines.lineEnd(last)-length, "\n"+above)...)
+	e.sendBatch(ops)
+
+	restore(-1)
+}
+
+// MoveLinesDown swaps the touched lines with the line below them.
+func (e *Editor) MoveLinesDown() {
+	lines := newLineIndex(e.RGA.GetText())
+	first, last := e.lineRange(lines)
+	if last+1 >= lines.lineCount() {
+		return
+	}
+	restore := e.saveSelection(lines)
+
+	below := string(lines.line(last + 1))
+	ops := e.RGA.LocalDeleteRange(lines.lineEnd(last), lines.lineEnd(last+1))
+	ops = append(ops, e.insertAt(lines.lineStart(first), below+"\n")...)
+	e.sendBatch(ops)
+
+	restore(1)
+}
+
+// JoinLines joins the line below onto the cursor line, or all selected
+// lines, replacing each break and the following indentation with a space.
+func (e *Editor) JoinLines() {
+	lines := newLineIndex(e.RGA.GetText())
+	first, last := e.lineRange(lines)
+	if last == first {
+		last++
+	}
+	if last >= lines.lineCount() {
+		return
+	}
+
+	e.ClearSelection()
+	ops := []crdt.Operation{}
+	joint := 0
+	// Work upwards so the offsets of the lines above stay valid.
+	for line := last; line > first; line-- {
+		start := lines.lineEnd(line - 1)
+		end := lines.firstNonBlank(line)
+		separator := " "
+		if start == lines.lineStart(line-1) || end == lines.lineEnd(line) || strings.HasSuffix(string(lines.line(line-1)), " ") {
+			separator = ""
+		}
+		ops = append(ops, e.RGA.LocalDeleteRange(start, end)...)
+		ops = append(ops, e.insertAt(start, separator)...)
+		joint = start
+	}
+	e.sendBatch(ops)
+	e.RGA.SetCursor(joint)
+	e.updateLocalCursor()
+}
+
+// IndentLines adds one level of indentation to every touched line that is
+// not empty.
+func (e *Editor) IndentLines() {
+	e.shiftLines(func(lines *lineIndex, line int) []crdt.Operation {
+		if lines.lineStart(line) == lines.lineEnd(line) {
+			return nil
+		}
+		return e.insertAt(lines.lineStart(line), e.Indent.unit())
+	})
+}
+
+// OutdentLines removes one level of indentation from every touched line.
+func (e *Editor) OutdentLines() {
+	e.shiftLines(func(lines *lineIndex, line int) []crdt.Operation {
+		start := lines.lineStart(line)
+		width := e.outdentWidth(lines.line(line))
+		if width == 0 {
+			return nil
+		}
+		return e.RGA.LocalDeleteRange(start, start+width)
+	})
+}
+
+// shiftLines changes the indentation of the touched lines and keeps the
+// selection on the same text.
+func (e *Editor) shiftLines(change func(lines *lineIndex, line int) []crdt.Operation) {
+	lines := newLineIndex(e.RGA.GetText())
+	first, last := e.lineRange(lines)
+	restore := e.saveSelection(lines)
+
+	ops := []crdt.Operation{}
+	for line := last; line >= first; line-- {
+		ops = append(ops, change(lines, line)...)
+	}
+	if len(ops) == 0 {
+		return
+	}
+	e.sendBatch(ops)
+	restore(0)
+}
+
+// lineRange returns the first and last line the selection touches, or the
+// cursor line. A selection ending at the start of a line leaves that line out.
+func (e *Editor) lineRange(lines *lineIndex) (int, int) {
+	start, end, ok := e.SelectionRange()
+	if !ok {
+		line, _ := lines.position(e.cursorOffset())
+		return line, line
+	}
+	first, _ := lines.position(start)
+	last, col := lines.position(end)
+	if col == 0 && last > first {
+		last--
+	}
+	return first, last
+}
+
+// saveSelection remembers the cursor and selection as lines and columns.
+// The returned function puts them back, moved by delta lines and with the
+// columns following changes to the indentation.
+func (e *Editor) saveSelection(lines *lineIndex) func(delta int) {
+	headLine, headCol := lines.position(e.cursorOffset())
+	headTail := len(lines.line(headLine)) - headCol
+	start, end, hasSelection := e.SelectionRange()
+	anchorLine, anchorCol := 0, 0
+	anchorTail := 0
+	if hasSelection {
+		anchor := start
+		if start == e.cursorOffset() {
+			anchor = end
+		}
+		anchorLine, anchorCol = lines.position(anchor)
+		anchorTail = len(lines.line(anchorLine)) - anchorCol
+	}
+
+	// Columns are kept relative to the line end, which indenting does not move.
+	column := func(lines *lineIndex, line int, col int, tail int) int {
+		length := len(lines.line(line))
+		if col == 0 || tail > length {
+			return col
+		}
+		return length - tail
+	}
+
+	return func(delta int) {
+		lines := newLineIndex(e.RGA.GetText())
+		e.ClearSelection()
+		if hasSelection {
+			line := anchorLine + delta
+			e.RGA.SetCursor(lines.offset(line, column(lines, line, anchorCol, anchorTail)))
+			e.startSelection()
+		}
+		line := headLine + delta
+		e.RGA.SetCursor(lines.offset(line, column(lines, line, headCol, headTail)))
+		e.updateLocalCursor()
+	}
+}
+
+// deleteRange deletes the visible range and sends it as one change.
+func (e *Editor) deleteRange(start int, end int) {
+	if start >= end {
+		return
+	}
+	e.sendBatch(e.RGA.LocalDeleteRange(start, end))
+	e.updateLocalCursor()
+}
+
+// insertAt inserts text at a visible offset and returns the operations.
+func (e *Editor) insertAt(offset int, text string) []crdt.Operation {
+	e.RGA.SetCursor(offset)
+	return e.RGA.LocalInsertText(text)
+}
+
+func (e *Editor) cursorOffset() int {
+	return e.RGA.ConvertCursior(e.RGA.CursorPosition)
+}
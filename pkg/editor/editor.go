@@ -1,16 +1,16 @@
 package editor
 
 import (
-	"bytes"
 	"edigo/pkg/crdt"
+	"edigo/pkg/editorconfig"
 	"edigo/pkg/highlighter"
 	"edigo/pkg/network"
 	"edigo/pkg/theme"
-	"encoding/gob"
 	"fmt"
 	"github.com/charmbracelet/lipgloss"
 	"net"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +25,8 @@ type CursorInfo struct {
 	LastMove   time.Time
 	Username   string
 	ThemeIndex int
+	View       network.ViewRange // lines the user currently sees, reported by presence messages
+	Document   string            // session path of the file the cursor is in
 }
 
 type Editor struct {
@@ -38,16 +40,49 @@ type Editor struct {
 	Error           string
 	Theme           *theme.Theme
 	SyntaxDef       highlighter.SyntaxDefinition
+	paneSyntax      map[string]highlighter.SyntaxDefinition // highlighters of the other panes, by file extension
 	LocalCursor     CursorInfo
 	RemoteCursors   map[string]CursorInfo
 	remoteCursorMu  sync.RWMutex
+	users           map[string]CursorInfo // names and colors given to peers, including those that left
 	updateTicker    *time.Ticker
 	nextThemeIndex  int
 	IsSharedSession bool
 	guestCounter    int
+	chat            []ChatEntry
+	unreadChat      int
+	chatMu          sync.Mutex
+	ScrollTop       int // first document line shown in the viewport
+	ScrollLeft      int // first display cell shown of every line
+	Selection       Selection
+	Visual          Visual // selection of Vim's visual mode
+	Indent          IndentSettings
+	Format          editorconfig.Settings // .editorconfig settings of the open file
+	Search          Search
+	Following       string // peer ID whose viewport we track, "" when not following
+	sentView        network.ViewRange
+	SessionRoot     string   // directory the session paths are relative to
+	Document        string   // session path of the open file
+	Buffers         []string // session paths of the files open as tabs, the others are parked documents
+	saved           uint32   // checksum of the open file when last saved
+	SharedPaths     []string // session paths guests may open
+	documents       map[string]*Document
+	remoteFormats   map[string]editorconfig.Settings // settings the host sent for its files
+	renamed         map[string]string                // old session paths of renamed files, to the new ones
+	renames         []rename                         // renames from the host waiting for the UI
+	disk            map[string]diskStamp             // files as we last loaded or saved them
+	Backup          string                           // "~" to back up files next to them, a directory, or "" for no backups
+	docMu           sync.Mutex
+	saveRequests    saveRequests
+	grep            grepState
+	pendingJump     jump
+	goal            goalColumn
+	kills           killRing
+	cursors         multiCursor
 }
 
 func NewEditor(content string, filePath string, siteID string, theme *theme.Theme) *Editor {
+	content, format := loadFormat(filePath, []byte(content))
 	rga := crdt.NewRGA(siteID)
 	for _, char := range content {
 		rga.LocalInsert(char)
@@ -58,13 +93,16 @@ func NewEditor(content string, filePath string, siteID string, theme *theme.Them
 	network.NewConnection = newConnection
 
 	fileExt := filepath.Ext(filePath)
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
 
 	editor := &Editor{
 		RGA:           rga,
 		Network:       network,
 		NewConnection: newConnection,
 		Theme:         theme,
-		SyntaxDef:     *highlighter.GetSyntaxDefiniton(fileExt),
 		Viewport:      viewport.New(80, 24),
 		LocalCursor: CursorInfo{
 			Position:   0,
@@ -73,6 +111,7 @@ func NewEditor(content string, filePath string, siteID string, theme *theme.Them
 			ThemeIndex: 0,
 		},
 		RemoteCursors:   make(map[string]CursorInfo),
+		users:           make(map[string]CursorInfo),
 		updateTicker:    time.NewTicker(100 * time.Millisecond),
 		Update:          make(chan struct{}, 1),
 		nextThemeIndex:  1,
@@ -80,8 +119,18 @@ func NewEditor(content string, filePath string, siteID string, theme *theme.Them
 		guestCounter:    0,
 		FilePath:        filePath,
 		FileExt:         fileExt,
+		SessionRoot:     filepath.Dir(absPath),
+		Document:        filepath.Base(filePath),
+		Buffers:         []string{filepath.Base(filePath)},
+		saved:           rga.Checksum,
+		documents:       make(map[string]*Document),
+		remoteFormats:   make(map[string]editorconfig.Settings),
+		renamed:         make(map[string]string),
+		disk:            map[string]diskStamp{filepath.Base(filePath): statFile(filePath)},
+		Format:          format,
 	}
 
+	editor.setSyntax(fileExt)
 	network.HostFilePath = filePath
 	network.HostFileExt = fileExt
 
@@ -92,105 +141,346 @@ func (e *Editor) Stop() {
 	e.updateTicker.Stop()
 }
 
+// InsertText replaces the selection with text, or inserts it at the cursor,
+// and sends it to collaborators as a single change.
+func (e *Editor) InsertText(text string) {
+	ops := e.deleteSelectionOps()
+	ops = append(ops, e.RGA.LocalInsertText(text)...)
+	e.sendBatch(ops)
+	e.updateLocalCursor()
+}
+
 func (e *Editor) InsertCharacter(ch rune) {
+	e.DeleteSelection()
 	op := e.RGA.LocalInsert(ch)
 	e.sendToRemote(op)
 	e.updateLocalCursor()
 }
 
 func (e *Editor) DeleteCharacterBeforeCursor() {
+	if e.DeleteSelection() || e.softBackspace() {
+		return
+	}
 	op := e.RGA.LocalDelete()
 	e.sendToRemote(op)
 	e.updateLocalCursor()
 }
 
 func (e *Editor) MoveCursorLeft() {
-	e.RGA.MoveCursorLeft()
-	e.updateLocalCursor()
+	e.moveCursor(e.RGA.MoveCursorLeft, false)
 }
 
 func (e *Editor) MoveCursorRight() {
-	e.RGA.MoveCursorRight()
-	e.updateLocalCursor()
+	e.moveCursor(e.RGA.MoveCursorRight, false)
 }
 
 func (e *Editor) MoveCursorUp() {
-	e.RGA.MoveCursorUp()
-	e.updateLocalCursor()
+	e.moveCursor(e.moveVertical(-1), false)
 }
 
 func (e *Editor) MoveCursorDown() {
-	e.RGA.MoveCursorDown()
+	e.moveCursor(e.moveVertical(1), false)
+}
+
+// moveCursor runs a cursor movement, either extending the selection from
+// where the cursor was or dropping the selection.
+func (e *Editor) moveCursor(move func(), selecting bool) {
+	if selecting || e.Selection.Mark {
+		e.startSelection()
+	} else {
+		e.ClearSelection()
+	}
+	move()
 	e.updateLocalCursor()
 }
 
 func (e *Editor) updateLocalCursor() {
+	e.resetGoal()
+	if e.cursors.running {
+		return
+	}
+	e.StopFollowing()
+	e.syncLocalCursor()
+	e.ScrollToCursor()
+}
+
+func (e *Editor) syncLocalCursor() {
 	e.LocalCursor.Position = e.RGA.CursorPosition
 	e.LocalCursor.LastMove = time.Now()
 	e.SendCursorUpdate()
 }
 
 func (e *Editor) SendCursorUpdate() {
-	go e.sendToRemote(crdt.Operation{Type: crdt.Move, ID: e.Network.ID, Character: 0, Position: e.LocalCursor.Position})
+	if e.private() {
+		return
+	}
+	op := crdt.Operation{Type: crdt.Move, ID: e.Network.ID, Character: 0, Position: e.LocalCursor.Position}
+	go e.sendMessage(network.Message{Type: network.OperationMessage, Document: e.Document, Operation: op})
 }
 
 func (e *Editor) sendToRemote(op crdt.Operation) {
+	if e.cursors.running {
+		e.cursors.batch = append(e.cursors.batch, op)
+		return
+	}
+	if e.private() {
+		return
+	}
+	e.sendMessage(network.Message{Type: network.OperationMessage, Document: e.Document, Operation: op})
+}
+
+// sendBatch sends the operations of one edit in a single message.
+func (e *Editor) sendBatch(ops []crdt.Operation) {
+	if len(ops) == 0 {
+		return
+	}
+	if e.cursors.running {
+		e.cursors.batch = append(e.cursors.batch, ops...)
+		return
+	}
+	if e.private() {
+		return
+	}
+	e.sendMessage(network.Message{Type: network.BatchMessage, Document: e.Document, Batch: ops})
+}
+
+// sendMessage hands a message to every guest when hosting, or to the host
+// when we joined a session.
+func (e *Editor) sendMessage(msg network.Message) {
 	if e.Network.IsHost {
-		for _, conn := range e.Network.Clients {
-			e.Network.SendOperation(op, conn)
+		e.Network.RelayM.Lock()
+		defer e.Network.RelayM.Unlock()
+		for _, conn := range e.Network.Clients() {
+			e.Network.SendMessage(msg, conn)
 		}
 	} else if e.Network.Host != nil {
-		e.Network.SendOperation(op, e.Network.Host)
+		e.Network.SendMessage(msg, e.Network.Host)
 	}
 }
 
 func (e *Editor) reciveInput(conn net.Conn) {
 	defer conn.Close()
 	for {
-		buf := make([]byte, 256)
-
-		conn.SetReadDeadline(time.Now().Add(30 * time.Millisecond))
-		_, err := conn.Read(buf)
-
+		msg, err := network.ReceiveMessage(conn)
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				continue
-			}
-
 			if e.Network.IsHost {
-				e.Network.RemoveClient(conn)
+				e.removeParticipant(conn)
 			}
-			if e.Network.Host != nil {
+			if e.Network.Host == conn {
 				e.Network.HostClosedSession()
+				e.Error = "Host closed the session"
 			}
 			e.Update <- struct{}{}
 			return
 		}
-		tmpbuff := bytes.NewBuffer(buf)
-		incomingOp := new(crdt.Operation)
 
-		gobobj := gob.NewDecoder(tmpbuff)
-		gobobj.Decode(incomingOp)
+		if e.Network.IsHost {
+			// A kicked guest may keep sending until its connection closes.
+			if !e.Network.IsClient(conn) {
+				continue
+			}
+			// Guests speak only for themselves.
+			msg.Sender = e.Network.PeerID(conn)
+		}
 
-		if incomingOp.Type == crdt.Move {
-			e.updateRemoteCursor(incomingOp.ID, incomingOp.Position)
-		} else {
-			oldPosition := e.LocalCursor.Position
-			e.RGA.ApplyOperation(*incomingOp)
-			e.adjustCursorAfterRemoteOp(oldPosition, incomingOp)
+		switch msg.Type {
+		case network.KickMessage:
+			if e.Network.Host == conn {
+				e.Network.HostClosedSession()
+				e.clearRemoteCursors()
+				e.Error = "Removed from session: " + msg.Text
+				e.Update <- struct{}{}
+				return
+			}
+			continue
+		case network.OperationMessage, network.BatchMessage, network.ChatMessage, network.ViewportMessage:
+			if e.Network.IsHost {
+				e.relay(conn, msg)
+			} else {
+				e.applyMessage(msg)
+			}
+			e.Update <- struct{}{}
+			continue
+		case network.OpenDocumentMessage:
+			if e.Network.IsHost {
+				e.handleOpenDocument(conn, msg.Document)
+			}
+			continue
+		case network.FileListMessage:
+			if e.Network.Host == conn {
+				e.receiveFileList(msg.Paths)
+				e.Update <- struct{}{}
+			}
+			continue
+		case network.SaveRequestMessage:
+			if e.Network.IsHost && e.IsShared(msg.Document) {
+				e.receiveSaveRequest(conn, msg)
+				e.Update <- struct{}{}
+			}
+			continue
+		case network.SavedMessage:
+			if e.Network.Host == conn {
+				e.markSaved(msg.Document)
+				e.Error = "Host saved " + msg.Document
+				e.Update <- struct{}{}
+			}
+			continue
+		case network.SaveDeclinedMessage:
+			if e.Network.Host == conn {
+				e.Error = "Host declined to save " + msg.Document
+				e.Update <- struct{}{}
+			}
+			continue
+		case network.GrepRequestMessage:
+			if e.Network.IsHost {
+				go e.serveGrep(conn, msg.Query)
+			}
+			continue
+		case network.GrepResultsMessage, network.GrepDoneMessage:
+			if e.Network.Host == conn {
+				e.receiveGrepHits(msg.Query.ID, msg.Hits, msg.Type == network.GrepDoneMessage)
+			}
+			continue
+		case network.FormatMessage:
+			if e.Network.Host == conn {
+				e.receiveFormat(msg.Document, msg.Format)
+				e.Update <- struct{}{}
+			}
+			continue
+		case network.RenameMessage:
+			if e.Network.Host == conn {
+				e.receiveRename(msg.Document, msg.Renamed)
+			}
+			continue
+		case network.SnapshotMessage:
+			if e.Network.Host == conn {
+				e.receiveSnapshot(msg.Document, msg.Snapshot)
+				e.Update <- struct{}{}
+			}
+			continue
 		}
+	}
+}
 
-		e.Update <- struct{}{}
+// relay applies a guest's message and passes it on to the other guests.
+// New guests get their snapshot under the same lock, so they see each edit
+// exactly once.
+func (e *Editor) relay(from net.Conn, msg network.Message) {
+	e.Network.RelayM.Lock()
+	defer e.Network.RelayM.Unlock()
 
-		if e.Network.IsHost {
-			for _, sendConn := range e.Network.Clients {
-				if sendConn == conn {
-					continue
-				}
-				e.Network.SendOperation(*incomingOp, sendConn)
+	if !e.applyMessage(msg) {
+		return
+	}
+	for _, conn := range e.Network.Clients() {
+		if conn != from {
+			e.Network.SendMessage(msg, conn)
+		}
+	}
+}
+
+// applyMessage applies an edit, cursor, chat or viewport message and reports
+// whether the other guests should get it too.
+func (e *Editor) applyMessage(msg network.Message) bool {
+	switch msg.Type {
+	case network.OperationMessage:
+		if e.Network.IsHost && !e.IsShared(msg.Document) {
+			return false
+		}
+		if msg.Document == e.Document {
+			e.applyRemoteOperation(&msg.Operation)
+		} else if msg.Operation.Type == crdt.Move {
+			e.updateRemoteCursor(msg.Operation.ID, msg.Operation.Position, msg.Document)
+		} else {
+			return e.applyDocumentOperation(msg.Document, msg.Operation)
+		}
+	case network.BatchMessage:
+		if e.Network.IsHost && !e.IsShared(msg.Document) {
+			return false
+		}
+		if msg.Document != e.Document {
+			// Edits we could not apply are not passed on either, or the
+			// guests would have text our snapshots lack.
+			applied := false
+			for _, op := range msg.Batch {
+				applied = e.applyDocumentOperation(msg.Document, op) || applied
 			}
+			return applied
+		}
+		for i := range msg.Batch {
+			e.applyRemoteOperation(&msg.Batch[i])
 		}
+	case network.ChatMessage:
+		e.receiveChat(msg)
+	case network.ViewportMessage:
+		e.updateRemoteView(msg.Sender, msg.Document, msg.View)
 	}
+	return true
+}
+
+func (e *Editor) applyRemoteOperation(op *crdt.Operation) {
+	if op.Type == crdt.Move {
+		e.updateRemoteCursor(op.ID, op.Position, e.Document)
+		if op.ID == e.Following {
+			e.followCursor()
+		}
+		return
+	}
+	oldPosition := e.LocalCursor.Position
+	e.RGA.ApplyOperation(*op)
+	e.adjustCursorAfterRemoteOp(oldPosition, op)
+}
+
+// KickParticipant removes a client from the hosted session and drops its cursor.
+func (e *Editor) KickParticipant(addr string, ban network.BanMode) {
+	participant, ok := e.Network.Kick(addr, ban)
+	if !ok {
+		e.Error = "Participant is no longer connected"
+		return
+	}
+	e.remoteCursorMu.Lock()
+	delete(e.RemoteCursors, participant.ID)
+	e.remoteCursorMu.Unlock()
+
+	name := participant.ID
+	if name == "" {
+		name = participant.Addr
+	}
+	switch ban {
+	case network.BanID:
+		e.Error = fmt.Sprintf("Kicked and banned %s", name)
+	case network.BanIP:
+		e.Error = fmt.Sprintf("Kicked and banned IP %s", participant.IP)
+	default:
+		e.Error = fmt.Sprintf("Kicked %s", name)
+	}
+}
+
+// welcome brings a guest that just got the snapshot up to date.
+func (e *Editor) welcome(conn net.Conn) {
+	e.Network.SendMessage(network.Message{Type: network.FileListMessage, Paths: e.SharedFiles()}, conn)
+	e.sendFormat(e.Network.HostDocument, conn)
+	e.sendChatHistory(conn)
+	// Let the newcomer know where we are.
+	e.sentView = network.ViewRange{}
+	e.SendCursorUpdate()
+	e.SendViewportUpdate()
+}
+
+func (e *Editor) removeParticipant(conn net.Conn) {
+	id := e.Network.PeerID(conn)
+	e.Network.RemoveClient(conn)
+	if id != "" {
+		e.remoteCursorMu.Lock()
+		delete(e.RemoteCursors, id)
+		e.remoteCursorMu.Unlock()
+	}
+}
+
+func (e *Editor) clearRemoteCursors() {
+	e.remoteCursorMu.Lock()
+	e.RemoteCursors = make(map[string]CursorInfo)
+	e.remoteCursorMu.Unlock()
 }
 
 func (e *Editor) adjustCursorAfterRemoteOp(oldPosition int, op *crdt.Operation) {
@@ -206,7 +496,7 @@ func (e *Editor) adjustCursorAfterRemoteOp(oldPosition int, op *crdt.Operation)
 			e.LocalCursor.Position = e.findValidCursorPosition(oldPosition)
 		}
 	}
-	e.updateLocalCursor()
+	e.syncLocalCursor()
 }
 
 func (e *Editor) findValidCursorPosition(position int) int {
@@ -220,27 +510,73 @@ func (e *Editor) findValidCursorPosition(position int) int {
 	return position
 }
 
-func (e *Editor) updateRemoteCursor(id string, position int) {
+func (e *Editor) updateRemoteCursor(id string, position int, document string) {
+	e.remoteCursorMu.Lock()
+	defer e.remoteCursorMu.Unlock()
+	cursor := e.lookupRemoteUser(id)
+	cursor.Position = position
+	cursor.Document = document
+	cursor.LastMove = time.Now()
+	e.RemoteCursors[id] = cursor
+}
+
+// remoteUser returns the name and color assigned to a peer, giving them to
+// the peer if it has not been seen yet. Only peers that move a cursor get
+// one, chat senders may have left long ago.
+func (e *Editor) remoteUser(id string) CursorInfo {
 	e.remoteCursorMu.Lock()
 	defer e.remoteCursorMu.Unlock()
-	cursor, exists := e.RemoteCursors[id]
+	return e.lookupRemoteUser(id)
+}
+
+func (e *Editor) lookupRemoteUser(id string) CursorInfo {
+	if cursor, exists := e.RemoteCursors[id]; exists {
+		return cursor
+	}
+	user, exists := e.users[id]
 	if !exists {
 		e.guestCounter++
-		cursor = CursorInfo{
+		user = CursorInfo{
 			Username:   fmt.Sprintf("guest%d", e.guestCounter),
 			ThemeIndex: e.nextThemeIndex,
 		}
 		e.nextThemeIndex = (e.nextThemeIndex + 1) % len(e.Theme.UserThemes)
+		e.users[id] = user
 	}
-	cursor.Position = position
-	cursor.LastMove = time.Now()
-	e.RemoteCursors[id] = cursor
+	return user
 }
 
 func (e *Editor) renderCursorWithName(cursor CursorInfo) string {
 	return e.Theme.RenderCursor(e.IsSharedSession, cursor.ThemeIndex)
 }
 
+// JoinSession connects to a session and shows the file the host shares first.
+func (e *Editor) JoinSession(sessionName string) error {
+	rga, err := e.Network.JoinSession(sessionName)
+	if err != nil {
+		return err
+	}
+
+	rga.Site = e.RGA.Site
+	crdt.InsertM.Lock()
+	*e.RGA = rga
+	crdt.InsertM.Unlock()
+
+	e.Document = e.Network.HostDocument
+	e.Buffers = []string{e.Document}
+	e.saved = e.RGA.Checksum
+	e.docMu.Lock()
+	e.documents = make(map[string]*Document)
+	e.docMu.Unlock()
+	e.renamed = make(map[string]string)
+	e.disk = make(map[string]diskStamp)
+	e.FilePath = e.Document
+	e.FileExt = filepath.Ext(e.Document)
+	e.Format = editorconfig.Settings{}
+	e.SharedPaths = []string{e.Document}
+	return nil
+}
+
 func (e *Editor) HandleConnections() {
 	for {
 		newConn := <-e.NewConnection
@@ -248,9 +584,11 @@ func (e *Editor) HandleConnections() {
 		e.Update <- struct{}{}
 		go e.reciveInput(newConn)
 
-		// Update SyntaxDef for clients when joining a session
-		if !e.Network.IsHost {
-			e.SyntaxDef = *highlighter.GetSyntaxDefiniton(e.Network.HostFileExt)
+		if e.Network.IsHost {
+			e.welcome(newConn)
+		} else {
+			// Update SyntaxDef for clients when joining a session
+			e.setSyntax(e.Network.HostFileExt)
 		}
 	}
 }
@@ -268,7 +606,7 @@ func (e *Editor) RenderDocument() string {
 		}
 		e.remoteCursorMu.RLock()
 		for _, remoteCursor := range e.RemoteCursors {
-			if i == remoteCursor.Position {
+			if remoteCursor.Document == e.Document && i == remoteCursor.Position {
 				result.WriteString(e.renderCursorWithName(remoteCursor))
 			}
 		}
@@ -281,7 +619,7 @@ func (e *Editor) RenderDocument() string {
 	}
 	e.remoteCursorMu.RLock()
 	for _, remoteCursor := range e.RemoteCursors {
-		if remoteCursor.Position == len(content) {
+		if remoteCursor.Document == e.Document && remoteCursor.Position == len(content) {
 			result.WriteString(e.renderCursorWithName(remoteCursor))
 		}
 	}
@@ -316,25 +654,26 @@ func (e *Editor) RenderContent() string {
 	var output strings.Builder
 	content := e.RGA.GetText()
 
-	lines := strings.Split(content, "\n")
-	lineNumberWidth := len(fmt.Sprintf("%d", len(lines)))
+	lines := newLineIndex(content)
+	lineNumberWidth := len(fmt.Sprintf("%d", lines.lineCount()))
 	totalLines := e.Viewport.Height - 2 // Subtracting 2 for header and footer
+	highlights := e.highlights()
+	cursors := e.cursorOffsets()
 
-	for i := 0; i < totalLines; i++ {
+	for row := 0; row < totalLines; row++ {
+		i := e.ScrollTop + row
 		lineNumber := ""
-		line := ""
 
-		if i < len(lines) {
+		if i < lines.lineCount() {
 			lineNumber = fmt.Sprintf("%*d", lineNumberWidth, i+1)
-			line = lines[i]
 		} else {
 			lineNumber = strings.Repeat(" ", lineNumberWidth-1) + "~"
 		}
 
 		renderedLineNumber := e.Theme.RenderLineNumber(lineNumber, lineNumberWidth)
 
-		if i < len(lines) {
-			renderedLine := e.renderLineWithCursors(line, i)
+		if i < lines.lineCount() {
+			renderedLine := e.renderLineWithCursors(lines.line(i), lines.lineStart(i), cursors, highlights)
 
 			output.WriteString(renderedLineNumber + renderedLine + "\n")
 		} else {
@@ -344,11 +683,17 @@ func (e *Editor) RenderContent() string {
 
 	headerMsg := fmt.Sprintf("File: %s", e.FilePath)
 	if e.Network.IsHost {
-		headerMsg += fmt.Sprintf(" Clients: %d", len(e.Network.Clients))
+		headerMsg += fmt.Sprintf(" Clients: %d", len(e.Network.Clients()))
 	}
 	if e.Network.Host != nil {
 		headerMsg += fmt.Sprintf(" Session: %s", e.Network.CurrentSession)
 	}
+	if n := len(e.cursors.carets); n > 0 {
+		headerMsg += fmt.Sprintf(" Cursors: %d", n+1)
+	}
+	if e.Following != "" {
+		headerMsg += fmt.Sprintf(" Following: %s", e.remoteUser(e.Following).Username)
+	}
 
 	header := e.Theme.RenderHeader(headerMsg)
 	footer := e.Theme.RenderStatusBar(e.Error)
@@ -360,76 +705,116 @@ func (e *Editor) RenderContent() string {
 	return lipgloss.NewStyle().MaxWidth(e.Viewport.Width).MaxHeight(e.Viewport.Height).Render(content)
 }
 
-func (e *Editor) renderLineWithCursors(line string, lineIndex int) string {
-	var result strings.Builder
-	lineStartIndex := e.getLineStartIndex(lineIndex)
+// highlight is a range of visible offsets drawn in its own style instead of
+// syntax colors, such as the selection.
+type highlight struct {
+	Start int
+	End   int
+	Style lipgloss.Style
+}
 
-	c_cursior := e.RGA.ConvertCursior(e.LocalCursor.Position)
+func highlightAt(highlights []highlight, offset int) (lipgloss.Style, bool) {
+	for _, h := range highlights {
+		if offset >= h.Start && offset < h.End {
+			return h.Style, true
+		}
+	}
+	return lipgloss.Style{}, false
+}
 
-	for colIndex, ch := range line {
-		absoluteIndex := lineStartIndex + colIndex
+// renderLineWithCursors draws the runes of a line that starts at the
+// visible offset lineStartIndex, with the cursors grouped by cursorOffsets.
+func (e *Editor) renderLineWithCursors(runes []rune, lineStartIndex int, cursors map[int][]CursorInfo, highlights []highlight) string {
+	var result strings.Builder
 
-		if absoluteIndex == c_cursior {
-			result.WriteString(e.renderCursorWithName(e.LocalCursor))
+	// Only the part of the line inside the horizontal scroll window is drawn.
+	first := columnAtWidth(runes, e.ScrollLeft, e.Indent.TabWidth)
+	last := columnAtWidth(runes, e.ScrollLeft+e.textWidth(), e.Indent.TabWidth)
+
+	// Split the line where highlights start or end, every segment is either
+	// highlighted or syntax colored as a whole.
+	bounds := []int{first, last}
+	for _, h := range highlights {
+		for _, bound := range []int{h.Start - lineStartIndex, h.End - lineStartIndex} {
+			if bound > first && bound < last {
+				bounds = append(bounds, bound)
+			}
 		}
+	}
+	sort.Ints(bounds)
 
-		e.remoteCursorMu.RLock()
-		for _, remoteCursor := range e.RemoteCursors {
-			c_remoteCursior := e.RGA.ConvertCursior(remoteCursor.Position)
-			if absoluteIndex == c_remoteCursior {
-				result.WriteString(e.renderCursorWithName(remoteCursor))
+	cell := displayWidth(runes[:first], e.Indent.TabWidth)
+	for i := 0; i+1 < len(bounds); i++ {
+		from, to := bounds[i], bounds[i+1]
+		if from == to {
+			continue
+		}
+
+		// Tabs are expanded in both, so the highlighter sees what is drawn.
+		var segment, plain strings.Builder
+		for col := from; col < to; col++ {
+			e.writeCursors(&segment, cursors[lineStartIndex+col])
+			width := cellWidth(runes[col], cell, e.Indent.TabWidth)
+			if runes[col] == '\t' {
+				segment.WriteString(strings.Repeat(" ", width))
+				plain.WriteString(strings.Repeat(" ", width))
+			} else {
+				segment.WriteRune(runes[col])
+				plain.WriteRune(runes[col])
 			}
+			cell += width
 		}
-		e.remoteCursorMu.RUnlock()
 
-		result.WriteRune(ch)
+		if style, ok := highlightAt(highlights, lineStartIndex+from); ok {
+			result.WriteString(style.Render(segment.String()))
+		} else {
+			result.WriteString(e.SyntaxDef.EmiteColorText(plain.String(), segment.String()))
+		}
 	}
 
 	// Check for cursors at the end of the line
-	if lineStartIndex+len(line) == c_cursior {
-		result.WriteString(e.renderCursorWithName(e.LocalCursor))
+	if last == len(runes) {
+		lineEnd := lineStartIndex + len(runes)
+		e.writeCursors(&result, cursors[lineEnd])
+		if style, ok := highlightAt(highlights, lineEnd); ok {
+			result.WriteString(style.Render(" "))
+		}
+	}
+
+	return result.String()
+}
+
+// cursorOffsets groups the local and remote cursors of the open document by
+// their visible offset.
+func (e *Editor) cursorOffsets() map[int][]CursorInfo {
+	cursors := map[int][]CursorInfo{}
+	local := e.RGA.ConvertCursior(e.LocalCursor.Position)
+	cursors[local] = append(cursors[local], e.LocalCursor)
+	for _, c := range e.cursors.carets {
+		offset := e.anchorOffset(c.head)
+		cursors[offset] = append(cursors[offset], e.LocalCursor)
 	}
 
 	e.remoteCursorMu.RLock()
+	defer e.remoteCursorMu.RUnlock()
 	for _, remoteCursor := range e.RemoteCursors {
-		c_remoteCursior := e.RGA.ConvertCursior(remoteCursor.Position)
-		if lineStartIndex+len(line) == c_remoteCursior {
-			result.WriteString(e.renderCursorWithName(remoteCursor))
+		if remoteCursor.Document != e.Document || remoteCursor.Position > len(e.RGA.Elements) {
+			continue
 		}
+		offset := e.RGA.ConvertCursior(remoteCursor.Position)
+		cursors[offset] = append(cursors[offset], remoteCursor)
 	}
-	e.remoteCursorMu.RUnlock()
-
-	colorText := e.SyntaxDef.EmiteColorText(line, result.String())
-
-	return colorText
+	return cursors
 }
 
-func (e *Editor) getLineStartIndex(lineIndex int) int {
-	content := e.RGA.GetText()
-	lines := strings.Split(content, "\n")
-	startIndex := 0
-	for i := 0; i < lineIndex; i++ {
-		startIndex += len(lines[i]) + 1 // +1 for the newline character
+func (e *Editor) writeCursors(result *strings.Builder, cursors []CursorInfo) {
+	for _, cursor := range cursors {
+		result.WriteString(e.renderCursorWithName(cursor))
 	}
-	return startIndex
 }
 
 func (e *Editor) getCursorLineAndColumn() (int, int) {
-	content := e.RGA.GetText()
-	line := 1
-	col := 1
-
-	for i, ch := range content {
-		if i == e.LocalCursor.Position {
-			break
-		}
-		if ch == '\n' {
-			line++
-			col = 1
-		} else {
-			col++
-		}
-	}
-
-	return line, col
+	lines := newLineIndex(e.RGA.GetText())
+	line, col := lines.position(e.RGA.ConvertCursior(e.LocalCursor.Position))
+	return line + 1, displayWidth(lines.line(line)[:col], e.Indent.TabWidth) + 1
 }
@@ -0,0 +1,252 @@
+package editor
+
+import (
+	"edigo/pkg/editorconfig"
+	"edigo/pkg/network"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileStatus reports whether a file on disk is open in a tab, has unsaved
+// changes and is shared in the session. Guests edit the host's files, so
+// their own files are never open.
+func (e *Editor) FileStatus(file string) (open bool, modified bool, shared bool) {
+	if e.Network.Host != nil {
+		return false, false, false
+	}
+	path, err := e.bufferPath(file)
+	if err != nil {
+		return false, false, false
+	}
+	return e.isBuffer(path), e.BufferModified(path), e.IsShared(path)
+}
+
+// CreateFile creates an empty file and opens it in a tab.
+func (e *Editor) CreateFile(file string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	e.OpenFile(file)
+	return nil
+}
+
+func (e *Editor) CreateDirectory(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+// RenameFile moves a file or a directory. Open files keep their tabs and
+// their sharing under the new path.
+func (e *Editor) RenameFile(from string, to string) error {
+	if _, err := os.Stat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	if e.Network.Host != nil {
+		return nil
+	}
+	oldPath, err := e.bufferPath(from)
+	if err != nil {
+		return nil
+	}
+	newPath, err := e.bufferPath(to)
+	if err != nil {
+		return nil
+	}
+	e.renameBuffers(oldPath, newPath)
+	return nil
+}
+
+// rename is a rename the host announced, done is closed once it is applied.
+type rename struct {
+	from string
+	to   string
+	done chan struct{}
+}
+
+// receiveRename hands a rename of the host to the UI, which owns the tabs and
+// the open file, and waits for it. The messages after it use the new paths.
+func (e *Editor) receiveRename(from string, to string) {
+	done := make(chan struct{})
+	e.docMu.Lock()
+	e.renames = append(e.renames, rename{from: from, to: to, done: done})
+	e.docMu.Unlock()
+	e.Update <- struct{}{}
+	<-done
+}
+
+// ApplyRenames applies the renames the host sent. The UI calls it on every
+// remote change.
+func (e *Editor) ApplyRenames() {
+	e.docMu.Lock()
+	renames := e.renames
+	e.renames = nil
+	e.docMu.Unlock()
+
+	for _, r := range renames {
+		e.renameBuffers(r.from, r.to)
+		e.Error = "Host renamed " + r.from + " to " + r.to
+		close(r.done)
+	}
+}
+
+// renameBuffers moves the session paths of everything below oldPath. The
+// host tells guests before it sends the new list of shared files, so they
+// stay in the files they have open.
+func (e *Editor) renameBuffers(oldPath string, newPath string) {
+	move := func(path string) string {
+		if path == oldPath {
+			return newPath
+		}
+		if rest, ok := strings.CutPrefix(path, oldPath+"/"); ok {
+			return newPath + "/" + rest
+		}
+		return path
+	}
+
+	for i, path := range e.Buffers {
+		e.Buffers[i] = move(path)
+	}
+
+	e.docMu.Lock()
+	documents := make(map[string]*Document, len(e.documents))
+	for path, doc := range e.documents {
+		doc.Path = move(path)
+		documents[doc.Path] = doc
+		e.recordRename(path, doc.Path)
+	}
+	e.documents = documents
+	formats := make(map[string]editorconfig.Settings, len(e.remoteFormats))
+	for path, format := range e.remoteFormats {
+		formats[move(path)] = format
+	}
+	e.remoteFormats = formats
+	disk := make(map[string]diskStamp, len(e.disk))
+	for path, stamp := range e.disk {
+		disk[move(path)] = stamp
+	}
+	e.disk = disk
+	sharedChanged := false
+	for i, path := range e.SharedPaths {
+		e.SharedPaths[i] = move(path)
+		sharedChanged = sharedChanged || e.SharedPaths[i] != path
+	}
+	sort.Strings(e.SharedPaths)
+	e.docMu.Unlock()
+
+	if document := move(e.Document); document != e.Document {
+		e.recordRename(e.Document, document)
+		e.Document = document
+		e.FilePath = e.documentDisplayPath(document)
+		e.FileExt = filepath.Ext(document)
+		e.setSyntax(e.FileExt)
+	}
+
+	e.remoteCursorMu.Lock()
+	for id, cursor := range e.RemoteCursors {
+		cursor.Document = move(cursor.Document)
+		e.RemoteCursors[id] = cursor
+	}
+	e.remoteCursorMu.Unlock()
+
+	if hostDocument := move(e.Network.HostDocument); hostDocument != e.Network.HostDocument {
+		e.Network.HostDocument = hostDocument
+		if e.Network.IsHost {
+			// Sessions found from now on announce the new name.
+			e.Network.HostFilePath = e.documentFilePath(hostDocument)
+			e.Network.HostFileExt = filepath.Ext(hostDocument)
+		}
+	}
+	if e.Network.IsHost && sharedChanged {
+		e.sendMessage(network.Message{Type: network.RenameMessage, Document: oldPath, Renamed: newPath})
+		e.sendMessage(network.Message{Type: network.FileListMessage, Paths: e.SharedFiles()})
+	}
+}
+
+// recordRename remembers where a file went for the panes that still show
+// it under its old path.
+func (e *Editor) recordRename(oldPath string, newPath string) {
+	if oldPath == newPath {
+		return
+	}
+	e.renamed[oldPath] = newPath
+	delete(e.renamed, newPath)
+}
+
+// currentPath follows the renames of a session path.
+func (e *Editor) currentPath(path string) string {
+	for i := 0; i < len(e.renamed); i++ {
+		next, ok := e.renamed[path]
+		if !ok {
+			break
+		}
+		path = next
+	}
+	return path
+}
+
+// DeleteFile removes a file, or a directory with everything in it. Open
+// files have to be closed first, as do files guests changed and nobody
+// saved yet. Deleted files are no longer shared.
+func (e *Editor) DeleteFile(file string) error {
+	if e.Network.Host != nil {
+		return os.RemoveAll(file)
+	}
+	path, err := e.bufferPath(file)
+	if err != nil {
+		return os.RemoveAll(file)
+	}
+	below := func(p string) bool {
+		return p == path || strings.HasPrefix(p, path+"/")
+	}
+	for _, buffer := range e.Buffers {
+		if below(buffer) {
+			return fmt.Errorf("Close %s before deleting it", buffer)
+		}
+	}
+	if e.Network.IsHost && below(e.Network.HostDocument) {
+		return fmt.Errorf("Guests start in %s, it can't be deleted", e.Network.HostDocument)
+	}
+	e.docMu.Lock()
+	for p, doc := range e.documents {
+		if below(p) && doc.RGA.Checksum != doc.Saved {
+			e.docMu.Unlock()
+			return fmt.Errorf("%s has unsaved changes from guests", p)
+		}
+	}
+	e.docMu.Unlock()
+
+	if err := os.RemoveAll(file); err != nil {
+		return err
+	}
+
+	e.docMu.Lock()
+	for p := range e.documents {
+		if below(p) {
+			delete(e.documents, p)
+			delete(e.disk, p)
+		}
+	}
+	shared := []string{}
+	for _, p := range e.SharedPaths {
+		if !below(p) {
+			shared = append(shared, p)
+		}
+	}
+	unshared := len(shared) != len(e.SharedPaths)
+	e.SharedPaths = shared
+	e.docMu.Unlock()
+
+	if e.Network.IsHost && unshared {
+		e.sendMessage(network.Message{Type: network.FileListMessage, Paths: e.SharedFiles()})
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package editor
+
+import (
+	"edigo/pkg/network"
+	"strings"
+	"time"
+)
+
+// Follow keeps our viewport on the cursor of the given peer until we move
+// our own cursor.
+func (e *Editor) Follow(id string) {
+	e.Following = id
+	e.followCursor()
+}
+
+func (e *Editor) StopFollowing() {
+	e.Following = ""
+}
+
+// RemoteUsers returns the IDs and details of everyone with a known cursor.
+func (e *Editor) RemoteUsers() map[string]CursorInfo {
+	e.remoteCursorMu.RLock()
+	defer e.remoteCursorMu.RUnlock()
+
+	users := make(map[string]CursorInfo, len(e.RemoteCursors))
+	for id, cursor := range e.RemoteCursors {
+		users[id] = cursor
+	}
+	return users
+}
+
+func (e *Editor) followCursor() {
+	e.remoteCursorMu.RLock()
+	cursor, exists := e.RemoteCursors[e.Following]
+	e.remoteCursorMu.RUnlock()
+	if !exists {
+		return
+	}
+	if cursor.Document != "" && cursor.Document != e.Document {
+		// The peer works in another shared file, go there first.
+		e.openDocument(cursor.Document)
+		return
+	}
+
+	line := e.lineOfPosition(cursor.Position)
+	height := e.visibleLines()
+
+	top := e.ScrollTop
+	if cursor.View.Bottom > cursor.View.Top && line >= cursor.View.Top && line <= cursor.View.Bottom {
+		// Mirror what the peer sees as long as their cursor is on their screen.
+		top = cursor.View.Top
+	} else if line < e.ScrollTop || line >= e.ScrollTop+height {
+		top = line - height/2
+	}
+	e.SetScrollTop(top)
+}
+
+func (e *Editor) updateRemoteView(id string, document string, view network.ViewRange) {
+	e.remoteCursorMu.Lock()
+	cursor := e.lookupRemoteUser(id)
+	cursor.View = view
+	cursor.Document = document
+	cursor.LastMove = time.Now()
+	e.RemoteCursors[id] = cursor
+	e.remoteCursorMu.Unlock()
+
+	if id == e.Following {
+		e.followCursor()
+	}
+}
+
+// SetScrollTop moves the viewport so that line top is the first visible line
+// and tells collaborators about the new viewport.
+func (e *Editor) SetScrollTop(top int) {
+	lastLine := strings.Count(e.RGA.GetText(), "\n")
+	if top > lastLine {
+		top = lastLine
+	}
+	if top < 0 {
+		top = 0
+	}
+	e.ScrollTop = top
+	e.SendViewportUpdate()
+}
+
+func (e *Editor) SendViewportUpdate() {
+	view := network.ViewRange{Top: e.ScrollTop, Bottom: e.ScrollTop + e.visibleLines() - 1}
+	if view == e.sentView || e.private() {
+		return
+	}
+	e.sentView = view
+	go e.sendMessage(network.Message{Type: network.ViewportMessage, Document: e.Document, View: view})
+}
+
+func (e *Editor) visibleLines() int {
+	height := e.Viewport.Height - 2 // header and footer
+	if height < 1 {
+		return 1
+	}
+	return height
+}
+
+// lineOfPosition returns the zero based line of an element index.
+func (e *Editor) lineOfPosition(position int) int {
+	if position > len(e.RGA.Elements) {
+		position = len(e.RGA.Elements)
+	}
+	visible := e.RGA.ConvertCursior(position)
+	runes := []rune(e.RGA.GetText())
+	if visible > len(runes) {
+		visible = len(runes)
+	}
+	return strings.Count(string(runes[:visible]), "\n")
+}
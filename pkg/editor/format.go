@@ -0,0 +1,75 @@
+package editor
+
+import (
+	"edigo/pkg/editorconfig"
+	"edigo/pkg/network"
+	"net"
+)
+
+// loadFormat resolves the .editorconfig settings of a file and decodes its
+// content. Line ending and charset fall back to what the file used.
+func loadFormat(path string, data []byte) (string, editorconfig.Settings) {
+	format, _ := editorconfig.Resolve(path)
+	text, detected := editorconfig.Decode(data, format.Charset)
+	if format.EndOfLine == "" {
+		format.EndOfLine = detected.EndOfLine
+	}
+	if format.Charset == "" {
+		format.Charset = detected.Charset
+	}
+	return text, format
+}
+
+// applyFormat lets the .editorconfig settings override the indentation of
+// the language.
+func (e *Editor) applyFormat() {
+	switch e.Format.IndentStyle {
+	case "tab":
+		e.Indent.UseTabs = true
+	case "space":
+		e.Indent.UseTabs = false
+	}
+	if e.Format.TabWidth > 0 {
+		e.Indent.TabWidth = e.Format.TabWidth
+	}
+	if e.Format.IndentSize > 0 {
+		e.Indent.IndentSize = e.Format.IndentSize
+	}
+}
+
+// EncodedDocument is the open file as it is written to disk.
+func (e *Editor) EncodedDocument() ([]byte, error) {
+	return e.Format.Encode(e.RenderDocumentWithoutLineNumbers())
+}
+
+// documentFormat returns the settings of a session file for guests.
+func (e *Editor) documentFormat(path string) editorconfig.Settings {
+	if path == e.Document {
+		return e.Format
+	}
+	e.docMu.Lock()
+	doc, exists := e.documents[path]
+	e.docMu.Unlock()
+	if exists {
+		return doc.Format
+	}
+	format, _ := editorconfig.Resolve(e.documentFilePath(path))
+	return format
+}
+
+func (e *Editor) sendFormat(path string, conn net.Conn) {
+	e.Network.SendMessage(network.Message{Type: network.FormatMessage, Document: path, Format: e.documentFormat(path)}, conn)
+}
+
+// receiveFormat keeps the host's settings of a session file, so guests
+// indent and save it the way the host does.
+func (e *Editor) receiveFormat(path string, format editorconfig.Settings) {
+	e.docMu.Lock()
+	e.remoteFormats[path] = format
+	e.docMu.Unlock()
+
+	if path == e.Document {
+		e.Format = format
+		e.setSyntax(e.FileExt)
+	}
+}
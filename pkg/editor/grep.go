@@ -0,0 +1,285 @@
+package editor
+
+import (
+	"bytes"
+	"edigo/pkg/crdt"
+	"edigo/pkg/network"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	maxGrepFiles    = 5000
+	maxGrepHits     = 1000
+	maxGrepFileSize = 1 << 20
+	maxGrepLineLen  = 240
+	grepBatchSize   = 100
+)
+
+type grepState struct {
+	mu      sync.Mutex
+	nextID  int
+	current *grepSearch
+}
+
+// grepSearch hands the hits of one search to the UI. Sending and closing
+// share a lock so a cancelled search never sends on a closed channel.
+type grepSearch struct {
+	id      int
+	results chan network.SearchHit
+	stop    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	once    sync.Once
+}
+
+func (s *grepSearch) send(hit network.SearchHit) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.results <- hit:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+func (s *grepSearch) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.results)
+	}
+}
+
+func (s *grepSearch) cancel() {
+	s.once.Do(func() { close(s.stop) })
+	s.end()
+}
+
+// jump is a cursor position to take once a document has been opened.
+type jump struct {
+	Document string
+	Line     int
+	Column   int
+}
+
+// GrepProject searches every file of the project, honouring .gitignore.
+// Guests search the files the host shares. Hits arrive on the returned
+// channel, which is closed when the search ends or another one starts.
+func (e *Editor) GrepProject(pattern string, options SearchOptions) (<-chan network.SearchHit, error) {
+	re, err := options.compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	search := e.startGrep()
+
+	if e.Network.Host != nil {
+		e.sendMessage(network.Message{Type: network.GrepRequestMessage, Query: network.SearchQuery{
+			ID:            search.id,
+			Pattern:       pattern,
+			Regex:         options.Regex,
+			CaseSensitive: options.CaseSensitive,
+			WholeWord:     options.WholeWord,
+		}})
+		return search.results, nil
+	}
+
+	go func() {
+		defer search.end()
+		e.grepFiles(re, e.projectFiles(maxGrepFiles), search.send)
+	}()
+	return search.results, nil
+}
+
+// StopGrep ends the running project search.
+func (e *Editor) StopGrep() {
+	e.grep.mu.Lock()
+	defer e.grep.mu.Unlock()
+	if e.grep.current != nil {
+		e.grep.current.cancel()
+		e.grep.current = nil
+	}
+}
+
+func (e *Editor) startGrep() *grepSearch {
+	e.grep.mu.Lock()
+	defer e.grep.mu.Unlock()
+	if e.grep.current != nil {
+		e.grep.current.cancel()
+	}
+	e.grep.nextID++
+	e.grep.current = &grepSearch{
+		id:      e.grep.nextID,
+		results: make(chan network.SearchHit, grepBatchSize),
+		stop:    make(chan struct{}),
+	}
+	return e.grep.current
+}
+
+// receiveGrepHits passes on the hits the host found for our search.
+func (e *Editor) receiveGrepHits(id int, hits []network.SearchHit, done bool) {
+	e.grep.mu.Lock()
+	search := e.grep.current
+	e.grep.mu.Unlock()
+	if search == nil || search.id != id {
+		return
+	}
+	for _, hit := range hits {
+		if !search.send(hit) {
+			return
+		}
+	}
+	if done {
+		search.end()
+	}
+}
+
+// serveGrep runs a guest's search over the shared files and streams the
+// hits back in batches.
+func (e *Editor) serveGrep(conn net.Conn, query network.SearchQuery) {
+	options := SearchOptions{Regex: query.Regex, CaseSensitive: query.CaseSensitive, WholeWord: query.WholeWord}
+	re, err := options.compile(query.Pattern)
+	if err == nil {
+		batch := []network.SearchHit{}
+		e.grepFiles(re, e.SharedFiles(), func(hit network.SearchHit) bool {
+			batch = append(batch, hit)
+			if len(batch) == grepBatchSize {
+				e.Network.SendMessage(network.Message{Type: network.GrepResultsMessage, Query: query, Hits: batch}, conn)
+				batch = []network.SearchHit{}
+			}
+			return true
+		})
+		if len(batch) > 0 {
+			e.Network.SendMessage(network.Message{Type: network.GrepResultsMessage, Query: query, Hits: batch}, conn)
+		}
+	}
+	e.Network.SendMessage(network.Message{Type: network.GrepDoneMessage, Query: query}, conn)
+}
+
+// grepFiles reports the first match of every matching line until emit
+// returns false or enough hits were found.
+func (e *Editor) grepFiles(re *regexp.Regexp, paths []string, emit func(network.SearchHit) bool) {
+	count := 0
+	for _, path := range paths {
+		text, ok := e.documentText(path)
+		if !ok {
+			continue
+		}
+		for i, line := range strings.Split(text, "\n") {
+			loc := re.FindStringIndex(line)
+			if loc == nil || loc[0] == loc[1] {
+				continue
+			}
+			hit := network.SearchHit{
+				Path:   path,
+				Line:   i,
+				Column: utf8.RuneCountInString(line[:loc[0]]),
+				Text:   truncateLine(line),
+			}
+			if !emit(hit) {
+				return
+			}
+			count++
+			if count >= maxGrepHits {
+				return
+			}
+		}
+	}
+}
+
+// documentText returns the text of a project file, preferring the version
+// we hold in memory. Binary and very large files are skipped.
+func (e *Editor) documentText(path string) (string, bool) {
+	// Grep runs beside the editor, which keeps editing these replicas.
+	crdt.InsertM.Lock()
+	var rga *crdt.RGA
+	if path == e.Document {
+		rga = e.RGA
+	} else {
+		e.docMu.Lock()
+		if doc, exists := e.documents[path]; exists {
+			rga = doc.RGA
+		}
+		e.docMu.Unlock()
+	}
+	var text string
+	if rga != nil {
+		text = rga.GetText()
+	}
+	crdt.InsertM.Unlock()
+	if rga != nil {
+		return text, true
+	}
+
+	info, err := os.Stat(e.documentFilePath(path))
+	if err != nil || info.Size() > maxGrepFileSize {
+		return "", false
+	}
+	content, err := os.ReadFile(e.documentFilePath(path))
+	if err != nil {
+		return "", false
+	}
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(content) {
+		return "", false
+	}
+	return string(content), true
+}
+
+func truncateLine(line string) string {
+	if len(line) <= maxGrepLineLen {
+		return line
+	}
+	cut := maxGrepLineLen
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut]
+}
+
+// OpenAt shows a project file with the cursor at the given line and column.
+// Guests jump once the host has sent the file.
+func (e *Editor) OpenAt(path string, line int, column int) {
+	e.StopFollowing()
+	if path == e.Document {
+		e.jumpTo(line, column)
+		return
+	}
+	e.docMu.Lock()
+	e.pendingJump = jump{Document: path, Line: line, Column: column}
+	e.docMu.Unlock()
+	e.openDocument(path)
+}
+
+func (e *Editor) jumpTo(line int, column int) {
+	lines := newLineIndex(e.RGA.GetText())
+	e.ClearSelection()
+	e.RGA.SetCursor(lines.offset(line, column))
+	e.updateLocalCursor()
+}
+
+// takePendingJump applies the jump waiting for the document just opened.
+func (e *Editor) takePendingJump() {
+	e.docMu.Lock()
+	pending := e.pendingJump
+	if pending.Document == e.Document {
+		e.pendingJump = jump{}
+	}
+	e.docMu.Unlock()
+
+	if pending.Document != "" && pending.Document == e.Document {
+		e.jumpTo(pending.Line, pending.Column)
+	}
+}
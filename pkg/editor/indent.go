@@ -0,0 +1,147 @@
+package editor
+
+import (
+	"edigo/pkg/highlighter"
+	"strings"
+)
+
+// IndentSettings says how the editor indents and how wide tabs are drawn.
+type IndentSettings struct {
+	UseTabs     bool
+	TabWidth    int
+	IndentSize  int
+	IndentAfter []string
+}
+
+// setSyntax picks the highlighter of a file type along with its
+// indentation, which the .editorconfig settings may override.
+func (e *Editor) setSyntax(fileExt string) {
+	e.useSyntax(*highlighter.GetSyntaxDefiniton(fileExt))
+}
+
+func (e *Editor) useSyntax(syntax highlighter.SyntaxDefinition) {
+	e.SyntaxDef = syntax
+	e.Indent = IndentSettings{
+		UseTabs:     e.SyntaxDef.UseTabs,
+		TabWidth:    e.SyntaxDef.TabWidth,
+		IndentSize:  e.SyntaxDef.IndentSize,
+		IndentAfter: e.SyntaxDef.IndentAfter,
+	}
+	e.applyFormat()
+	if e.Indent.TabWidth < 1 {
+		e.Indent.TabWidth = 4
+	}
+	if e.Indent.IndentSize < 1 {
+		e.Indent.IndentSize = e.Indent.TabWidth
+	}
+}
+
+// unit is the text of one indentation level.
+func (s IndentSettings) unit() string {
+	if s.UseTabs {
+		return "\t"
+	}
+	return strings.Repeat(" ", s.IndentSize)
+}
+
+// Tab indents the selected lines, or inserts one indent at the cursor. Soft
+// tabs fill up to the next indentation stop.
+func (e *Editor) Tab() {
+	lines := newLineIndex(e.RGA.GetText())
+	if first, last := e.lineRange(lines); first != last {
+		e.IndentLines()
+		return
+	}
+	if e.Indent.UseTabs {
+		e.InsertText("\t")
+		return
+	}
+	line, col := lines.position(e.cursorOffset())
+	cell := displayWidth(lines.line(line)[:col], e.Indent.TabWidth)
+	e.InsertText(strings.Repeat(" ", e.Indent.IndentSize-cell%e.Indent.IndentSize))
+}
+
+// NewLine breaks the line and indents the new one like the current one,
+// one level deeper after an opening bracket or a Python colon. Between a
+// pair of brackets the closing one moves to a line of its own.
+func (e *Editor) NewLine() {
+	ops := e.deleteSelectionOps()
+
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	line, col := lines.position(offset)
+	before := lines.line(line)[:col]
+	indent := leadingWhitespace(before)
+
+	trimmed := strings.TrimRight(string(before), " \t")
+	deeper := false
+	for _, opener := range e.Indent.IndentAfter {
+		if strings.HasSuffix(trimmed, opener) {
+			deeper = true
+			break
+		}
+	}
+	if !deeper {
+		ops = append(ops, e.RGA.LocalInsertText("\n"+indent)...)
+		e.sendBatch(ops)
+		e.updateLocalCursor()
+		return
+	}
+
+	inner := "\n" + indent + e.Indent.unit()
+	ops = append(ops, e.RGA.LocalInsertText(inner)...)
+	if offset < len(lines.text) && closesBracket(trimmed, lines.text[offset]) {
+		ops = append(ops, e.RGA.LocalInsertText("\n"+indent)...)
+		e.RGA.SetCursor(offset + len([]rune(inner)))
+	}
+	e.sendBatch(ops)
+	e.updateLocalCursor()
+}
+
+// softBackspace deletes the spaces back to the previous indentation stop
+// when the cursor is inside space indentation. It reports whether it did.
+func (e *Editor) softBackspace() bool {
+	if e.Indent.UseTabs {
+		return false
+	}
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	line, col := lines.position(offset)
+	before := lines.line(line)[:col]
+	if col == 0 || strings.Trim(string(before), " ") != "" {
+		return false
+	}
+	width := (col-1)%e.Indent.IndentSize + 1
+	e.deleteRange(offset-width, offset)
+	return true
+}
+
+// outdentWidth is the number of characters one outdent removes from the
+// start of a line: a tab or up to one level of spaces.
+func (e *Editor) outdentWidth(line []rune) int {
+	if len(line) > 0 && line[0] == '\t' {
+		return 1
+	}
+	width := 0
+	for width < len(line) && width < e.Indent.IndentSize && line[width] == ' ' {
+		width++
+	}
+	return width
+}
+
+func leadingWhitespace(line []rune) string {
+	end := 0
+	for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+		end++
+	}
+	return string(line[:end])
+}
+
+// closesBracket reports whether ch closes the bracket the text ends with.
+func closesBracket(text string, ch rune) bool {
+	if text == "" {
+		return false
+	}
+	partner, ok := bracketPairs[rune(text[len(text)-1])]
+	return ok && partner == ch && strings.ContainsRune("([{", rune(text[len(text)-1]))
+}
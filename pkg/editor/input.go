@@ -1,38 +1,182 @@
 package editor
 
 import (
-	"github.com/charmbracelet/bubbles/key"
+	"edigo/pkg/keymap"
+
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 type InputHandler struct {
-	Editor *Editor
+	Editor  *Editor
+	Keymap  *keymap.Keymap
+	Vim     *Vim // nil unless Vim mode is on
+	actions map[string]func()
+}
+
+// editorCommand is a command of the text area.
+type editorCommand struct {
+	keymap.Command
+	run func(e *Editor)
+}
+
+// Terminals rarely report ctrl+backspace and ctrl+delete, so word deletion
+// sits on the alt and readline keys.
+var editorCommands = []editorCommand{
+	{keymap.Command{Name: "move-left", Description: "Move the cursor left", Keys: []string{"left"}}, (*Editor).MoveCursorLeft},
+	{keymap.Command{Name: "move-right", Description: "Move the cursor right", Keys: []string{"right"}}, (*Editor).MoveCursorRight},
+	{keymap.Command{Name: "move-up", Description: "Move the cursor up", Keys: []string{"up"}}, (*Editor).MoveCursorUp},
+	{keymap.Command{Name: "move-down", Description: "Move the cursor down", Keys: []string{"down"}}, (*Editor).MoveCursorDown},
+	{keymap.Command{Name: "move-line-start", Description: "Go to the first non-blank character or the start of the line", Keys: []string{"home"}}, (*Editor).MoveCursorHome},
+	{keymap.Command{Name: "move-line-end", Description: "Go to the end of the line", Keys: []string{"end"}}, (*Editor).MoveCursorEnd},
+	{keymap.Command{Name: "move-word-left", Description: "Go to the previous word", Keys: []string{"ctrl+left"}}, (*Editor).MoveWordLeft},
+	{keymap.Command{Name: "move-word-right", Description: "Go to the next word", Keys: []string{"ctrl+right"}}, (*Editor).MoveWordRight},
+	{keymap.Command{Name: "move-document-start", Description: "Go to the start of the file", Keys: []string{"ctrl+home"}}, (*Editor).MoveDocumentStart},
+	{keymap.Command{Name: "move-document-end", Description: "Go to the end of the file", Keys: []string{"ctrl+end"}}, (*Editor).MoveDocumentEnd},
+	{keymap.Command{Name: "jump-to-bracket", Description: "Go to the matching bracket", Keys: []string{"ctrl+]"}}, (*Editor).JumpToMatchingBracket},
+	{keymap.Command{Name: "page-up", Description: "Move one page up", Keys: []string{"pgup"}}, (*Editor).PageUp},
+	{keymap.Command{Name: "page-down", Description: "Move one page down", Keys: []string{"pgdown"}}, (*Editor).PageDown},
+	{keymap.Command{Name: "select-left", Description: "Extend the selection left", Keys: []string{"shift+left"}}, (*Editor).SelectLeft},
+	{keymap.Command{Name: "select-right", Description: "Extend the selection right", Keys: []string{"shift+right"}}, (*Editor).SelectRight},
+	{keymap.Command{Name: "select-up", Description: "Extend the selection up", Keys: []string{"shift+up"}}, (*Editor).SelectUp},
+	{keymap.Command{Name: "select-down", Description: "Extend the selection down", Keys: []string{"shift+down"}}, (*Editor).SelectDown},
+	{keymap.Command{Name: "select-line-start", Description: "Extend the selection to the start of the line", Keys: []string{"shift+home"}}, (*Editor).SelectToLineStart},
+	{keymap.Command{Name: "select-line-end", Description: "Extend the selection to the end of the line", Keys: []string{"shift+end"}}, (*Editor).SelectToLineEnd},
+	{keymap.Command{Name: "select-word-left", Description: "Extend the selection to the previous word", Keys: []string{"ctrl+shift+left"}}, (*Editor).SelectWordLeft},
+	{keymap.Command{Name: "select-word-right", Description: "Extend the selection to the next word", Keys: []string{"ctrl+shift+right"}}, (*Editor).SelectWordRight},
+	{keymap.Command{Name: "select-document-start", Description: "Extend the selection to the start of the file", Keys: []string{"ctrl+shift+home"}}, (*Editor).SelectDocumentStart},
+	{keymap.Command{Name: "select-document-end", Description: "Extend the selection to the end of the file", Keys: []string{"ctrl+shift+end"}}, (*Editor).SelectDocumentEnd},
+	{keymap.Command{Name: "select-all", Description: "Select the whole file", Keys: []string{"ctrl+a"}}, (*Editor).SelectAll},
+	{keymap.Command{Name: "new-line", Description: "Break the line and indent", Keys: []string{"enter"}}, (*Editor).NewLine},
+	{keymap.Command{Name: "indent", Description: "Insert a tab or indent the selected lines", Keys: []string{"tab"}}, (*Editor).Tab},
+	{keymap.Command{Name: "outdent", Description: "Outdent the selected lines", Keys: []string{"shift+tab"}}, (*Editor).OutdentLines},
+	{keymap.Command{Name: "delete-backward", Description: "Delete the character before the cursor", Keys: []string{"backspace", "ctrl+h"}}, (*Editor).DeleteCharacterBeforeCursor},
+	{keymap.Command{Name: "delete-forward", Description: "Delete the character after the cursor", Keys: []string{"delete"}}, (*Editor).DeleteCharacterAfterCursor},
+	{keymap.Command{Name: "delete-word-backward", Description: "Delete the word before the cursor", Keys: []string{"alt+backspace", "ctrl+w"}}, (*Editor).DeleteWordBeforeCursor},
+	{keymap.Command{Name: "delete-word-forward", Description: "Delete the word after the cursor", Keys: []string{"alt+delete", "alt+d"}}, (*Editor).DeleteWordAfterCursor},
+	{keymap.Command{Name: "delete-lines", Description: "Delete the current or selected lines", Keys: []string{"ctrl+k"}}, (*Editor).DeleteLines},
+	{keymap.Command{Name: "duplicate-lines", Description: "Duplicate the current or selected lines", Keys: []string{"ctrl+d"}}, (*Editor).DuplicateLines},
+	{keymap.Command{Name: "move-lines-up", Description: "Move the current or selected lines up", Keys: []string{"alt+up"}}, (*Editor).MoveLinesUp},
+	{keymap.Command{Name: "move-lines-down", Description: "Move the current or selected lines down", Keys: []string{"alt+down"}}, (*Editor).MoveLinesDown},
+	{keymap.Command{Name: "join-lines", Description: "Join the next line onto the current one", Keys: []string{"ctrl+j"}}, (*Editor).JoinLines},
+	{keymap.Command{Name: "add-cursor-above", Description: "Add a cursor on the line above", Keys: []string{"alt+shift+up"}}, (*Editor).AddCursorAbove},
+	{keymap.Command{Name: "add-cursor-below", Description: "Add a cursor on the line below", Keys: []string{"alt+shift+down"}}, (*Editor).AddCursorBelow},
+	{keymap.Command{Name: "add-cursor-next-match", Description: "Select the word, then add a cursor at the next occurrence", Keys: []string{"alt+n"}}, (*Editor).AddCursorAtNextMatch},
+	{keymap.Command{Name: "select-all-matches", Description: "Put a cursor on every occurrence of the selection", Keys: []string{"alt+L"}}, (*Editor).SelectAllMatches},
+	{keymap.Command{Name: "set-mark", Description: "Start a region that follows the cursor"}, (*Editor).SetMark},
+	{keymap.Command{Name: "cancel", Description: "Drop the selection or region"}, (*Editor).ClearSelection},
+	{keymap.Command{Name: "kill-line", Description: "Kill to the end of the line"}, (*Editor).KillLine},
+	{keymap.Command{Name: "kill-region", Description: "Kill the region"}, (*Editor).KillRegion},
+	{keymap.Command{Name: "copy-region", Description: "Put the region on the kill ring"}, (*Editor).CopyRegion},
+	{keymap.Command{Name: "kill-word", Description: "Kill to the end of the next word"}, (*Editor).KillWord},
+	{keymap.Command{Name: "backward-kill-word", Description: "Kill back to the start of the previous word"}, (*Editor).BackwardKillWord},
+	{keymap.Command{Name: "yank", Description: "Insert the newest kill"}, (*Editor).Yank},
+	{keymap.Command{Name: "yank-pop", Description: "Replace the yanked text with an older kill"}, (*Editor).YankPop},
+}
+
+// singleCursor lists the commands that run once instead of at every cursor.
+// They drop the extra cursors first, unless they manage the cursors.
+var singleCursor = map[string]bool{
+	"select-all":            false,
+	"page-up":               false,
+	"page-down":             false,
+	"cancel":                false,
+	"kill-line":             false,
+	"kill-region":           false,
+	"copy-region":           false,
+	"kill-word":             false,
+	"backward-kill-word":    false,
+	"yank":                  false,
+	"yank-pop":              false,
+	"add-cursor-above":      true,
+	"add-cursor-below":      true,
+	"add-cursor-next-match": true,
+	"select-all-matches":    true,
 }
 
-func NewInputHandler(editor *Editor) *InputHandler {
+// Commands lists the commands of the text area with their default keys.
+func Commands() []keymap.Command {
+	commands := make([]keymap.Command, len(editorCommands))
+	for i, command := range editorCommands {
+		commands[i] = command.Command
+	}
+	return commands
+}
+
+func NewInputHandler(editor *Editor, keys *keymap.Keymap) *InputHandler {
+	actions := make(map[string]func(), len(editorCommands))
+	for _, command := range editorCommands {
+		run := command.run
+		actions[command.Name] = func() { run(editor) }
+	}
 	return &InputHandler{
-		Editor: editor,
+		Editor:  editor,
+		Keymap:  keys,
+		actions: actions,
 	}
 }
 
-func (ih *InputHandler) HandleKeyMsg(msg tea.KeyMsg) {
+// Run runs an editor command by name and reports whether there is one.
+func (ih *InputHandler) Run(name string) bool {
+	action, ok := ih.actions[name]
+	if !ok {
+		return false
+	}
+	ih.Editor.kills.advance()
+	keep, single := singleCursor[name]
 	switch {
-	case key.Matches(msg, key.NewBinding(key.WithKeys("left"))):
-		ih.Editor.MoveCursorLeft()
-	case key.Matches(msg, key.NewBinding(key.WithKeys("right"))):
-		ih.Editor.MoveCursorRight()
-	case key.Matches(msg, key.NewBinding(key.WithKeys("up"))):
-		ih.Editor.MoveCursorUp()
-	case key.Matches(msg, key.NewBinding(key.WithKeys("down"))):
-		ih.Editor.MoveCursorDown()
-	case key.Matches(msg, key.NewBinding(key.WithKeys("backspace", "ctrl+h"))):
-		ih.Editor.DeleteCharacterBeforeCursor()
-	case key.Matches(msg, key.NewBinding(key.WithKeys("enter"))):
-		ih.Editor.InsertCharacter('\n')
-	case key.Matches(msg, key.NewBinding(key.WithKeys("delete"))):
+	case single && !keep:
+		ih.Editor.DropCursors()
+		action()
+	case single:
+		action()
 	default:
-		if len(msg.String()) == 1 { // Only handle single characters
-			ih.Editor.InsertCharacter(rune(msg.String()[0]))
-		}
+		ih.Editor.forEachCursor(action)
+	}
+	return true
+}
+
+func (ih *InputHandler) HandleKeyMsg(msg tea.KeyMsg) {
+	if msg.Paste {
+		// Bracketed paste from the terminal.
+		ih.Editor.kills.advance()
+		ih.Editor.Paste(string(msg.Runes))
+		return
+	}
+	if ih.Run(ih.Keymap.Command(msg.String())) {
+		return
+	}
+	// Runes arrive decoded, possibly several at once from an IME.
+	if (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) && !msg.Alt {
+		ih.Editor.kills.advance()
+		ih.Editor.forEachCursor(func() { ih.Editor.InsertText(string(msg.Runes)) })
+	}
+}
+
+// HandleMouseMsg handles mouse events with coordinates relative to the
+// rendered editor content.
+func (ih *InputHandler) HandleMouseMsg(msg tea.MouseMsg) {
+	switch {
+	case msg.Action == tea.MouseActionRelease:
+		ih.Editor.MouseRelease()
+		return
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		ih.Editor.MousePress(msg.X, msg.Y)
+		return
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionMotion:
+		ih.Editor.MouseDrag(msg.X, msg.Y)
+		return
+	case msg.Action != tea.MouseActionPress:
+		return
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		ih.Editor.ScrollWheel(true)
+	case tea.MouseButtonWheelDown:
+		ih.Editor.ScrollWheel(false)
+	case tea.MouseButtonWheelLeft:
+		ih.Editor.ScrollWheelHorizontal(true)
+	case tea.MouseButtonWheelRight:
+		ih.Editor.ScrollWheelHorizontal(false)
 	}
 }
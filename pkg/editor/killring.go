@@ -0,0 +1,136 @@
+package editor
+
+import "edigo/pkg/clipboard"
+
+const killRingSize = 60
+
+// killRing keeps the text of the last kills for yanking, newest first.
+// Kills right after each other add to the newest entry, and a yank can be
+// swapped for older entries while nothing else happened in between.
+type killRing struct {
+	entries []string
+	yank    int    // entry the last yank inserted
+	start   string // anchor in front of the yanked text
+	length  int
+	last    string // what the previous command did: "kill", "yank" or ""
+	current string
+}
+
+// advance starts a new command.
+func (k *killRing) advance() {
+	k.last, k.current = k.current, ""
+}
+
+// add puts killed text on the ring, in front of the newest entry when the
+// kill went backwards. The newest entry also goes to the system clipboard.
+func (k *killRing) add(text string, backwards bool) {
+	k.current = "kill"
+	if text == "" {
+		return
+	}
+	switch {
+	case k.last == "kill" && len(k.entries) > 0 && backwards:
+		k.entries[0] = text + k.entries[0]
+	case k.last == "kill" && len(k.entries) > 0:
+		k.entries[0] += text
+	default:
+		k.entries = append([]string{text}, k.entries...)
+		if len(k.entries) > killRingSize {
+			k.entries = k.entries[:killRingSize]
+		}
+	}
+	clipboard.Write(k.entries[0])
+}
+
+// SetMark starts a region at the cursor that follows the cursor until the
+// next edit. Setting it again on an empty region drops it.
+func (e *Editor) SetMark() {
+	if e.Selection.Mark && !e.HasSelection() {
+		e.ClearSelection()
+		return
+	}
+	e.Selection = Selection{Anchor: e.anchorAt(e.RGA.CursorPosition), Active: true, Mark: true}
+}
+
+// KillLine kills the rest of the line, or the line break at its end.
+func (e *Editor) KillLine() {
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	line, _ := lines.position(offset)
+	end := lines.lineEnd(line)
+	if offset == end {
+		end = min(end+1, len(lines.text))
+	}
+	e.kill(offset, end, false)
+}
+
+// KillRegion kills the text between the mark and the cursor.
+func (e *Editor) KillRegion() {
+	if start, end, ok := e.SelectionRange(); ok {
+		e.kill(start, end, false)
+	}
+}
+
+// CopyRegion puts the region on the kill ring and drops the mark.
+func (e *Editor) CopyRegion() {
+	if e.HasSelection() {
+		e.kills.add(e.SelectedText(), false)
+	}
+	e.ClearSelection()
+}
+
+func (e *Editor) KillWord() {
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	e.kill(offset, lines.wordRight(offset), false)
+}
+
+func (e *Editor) BackwardKillWord() {
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	e.kill(lines.wordLeft(offset), offset, true)
+}
+
+func (e *Editor) kill(start int, end int, backwards bool) {
+	e.kills.add(string([]rune(e.RGA.GetText())[start:end]), backwards)
+	e.ClearSelection()
+	e.deleteRange(start, end)
+}
+
+// Yank inserts the newest kill. Text copied in another program since then
+// comes first.
+func (e *Editor) Yank() {
+	if text := clipboard.Read(); text != "" && (len(e.kills.entries) == 0 || text != e.kills.entries[0]) {
+		e.kills.last = ""
+		e.kills.add(text, false)
+	}
+	if len(e.kills.entries) == 0 {
+		return
+	}
+	e.kills.yank = 0
+	e.yankEntry()
+}
+
+// YankPop replaces the text just yanked with the next older kill.
+func (e *Editor) YankPop() {
+	if e.kills.last != "yank" || len(e.kills.entries) == 0 {
+		return
+	}
+	start := e.anchorOffset(e.kills.start)
+	e.ClearSelection()
+	e.sendBatch(e.RGA.LocalDeleteRange(start, start+e.kills.length))
+	e.kills.yank = (e.kills.yank + 1) % len(e.kills.entries)
+	e.yankEntry()
+}
+
+func (e *Editor) yankEntry() {
+	text := e.kills.entries[e.kills.yank]
+	ops := e.deleteSelectionOps()
+	start := e.cursorOffset()
+	ops = append(ops, e.RGA.LocalInsertText(text)...)
+	e.sendBatch(ops)
+	e.kills.start = e.anchorAt(e.RGA.ElementIndex(start))
+	e.kills.length = len([]rune(text))
+	e.kills.current = "yank"
+	e.updateLocalCursor()
+}
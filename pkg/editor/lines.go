@@ -0,0 +1,292 @@
+package editor
+
+import (
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// lineIndex splits the visible text into lines once so that offsets,
+// lines and columns can be converted without walking the text rune by rune.
+// Offsets and columns count runes, display columns count terminal cells.
+type lineIndex struct {
+	text   []rune
+	starts []int
+}
+
+func newLineIndex(text string) *lineIndex {
+	runes := []rune(text)
+	starts := []int{0}
+	for i, ch := range runes {
+		if ch == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{text: runes, starts: starts}
+}
+
+func (li *lineIndex) lineCount() int {
+	return len(li.starts)
+}
+
+func (li *lineIndex) lineStart(line int) int {
+	return li.starts[li.clampLine(line)]
+}
+
+// lineEnd returns the offset of the newline ending the line, or the end of
+// the text for the last line.
+func (li *lineIndex) lineEnd(line int) int {
+	line = li.clampLine(line)
+	if line+1 < len(li.starts) {
+		return li.starts[line+1] - 1
+	}
+	return len(li.text)
+}
+
+func (li *lineIndex) line(line int) []rune {
+	return li.text[li.lineStart(line):li.lineEnd(line)]
+}
+
+// position returns the line and column of an offset.
+func (li *lineIndex) position(offset int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(li.text) {
+		offset = len(li.text)
+	}
+
+	low, high := 0, len(li.starts)-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		if li.starts[mid] <= offset {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low, offset - li.starts[low]
+}
+
+// offset returns the offset of a line and column, clamping the column to
+// the length of the line.
+func (li *lineIndex) offset(line int, col int) int {
+	start := li.lineStart(line)
+	end := li.lineEnd(line)
+	if col < 0 {
+		col = 0
+	}
+	if start+col > end {
+		return end
+	}
+	return start + col
+}
+
+func (li *lineIndex) clampLine(line int) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= len(li.starts) {
+		return len(li.starts) - 1
+	}
+	return line
+}
+
+// displayWidth is the number of terminal cells the runes of a line prefix
+// occupy. Tabs reach up to the next tab stop.
+func displayWidth(runes []rune, tabWidth int) int {
+	width := 0
+	for _, ch := range runes {
+		width += cellWidth(ch, width, tabWidth)
+	}
+	return width
+}
+
+// cellWidth is the number of cells a rune drawn at the given cell takes.
+func cellWidth(ch rune, cell int, tabWidth int) int {
+	if ch == '\t' {
+		if tabWidth < 1 {
+			tabWidth = 1
+		}
+		return tabWidth - cell%tabWidth
+	}
+	return runewidth.RuneWidth(ch)
+}
+
+// columnAtWidth returns the column of the rune drawn at the given cell.
+func columnAtWidth(line []rune, width int, tabWidth int) int {
+	cells := 0
+	for col, ch := range line {
+		cells += cellWidth(ch, cells, tabWidth)
+		if cells > width {
+			return col
+		}
+	}
+	return len(line)
+}
+
+// firstNonBlank returns the offset of the first character of a line that is
+// not a space or tab, or the line end for blank lines.
+func (li *lineIndex) firstNonBlank(line int) int {
+	end := li.lineEnd(line)
+	for offset := li.lineStart(line); offset < end; offset++ {
+		if li.text[offset] != ' ' && li.text[offset] != '\t' {
+			return offset
+		}
+	}
+	return end
+}
+
+type charClass int
+
+const (
+	spaceClass charClass = iota
+	wordClass
+	punctClass
+)
+
+func classOf(ch rune) charClass {
+	switch {
+	case unicode.IsSpace(ch):
+		return spaceClass
+	case ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch):
+		return wordClass
+	}
+	return punctClass
+}
+
+// wordRight returns the offset after the next word, skipping the blanks
+// in front of it.
+func (li *lineIndex) wordRight(offset int) int {
+	for offset < len(li.text) && classOf(li.text[offset]) == spaceClass {
+		offset++
+	}
+	if offset == len(li.text) {
+		return offset
+	}
+	class := classOf(li.text[offset])
+	for offset < len(li.text) && classOf(li.text[offset]) == class {
+		offset++
+	}
+	return offset
+}
+
+// wordLeft returns the offset of the start of the previous word.
+func (li *lineIndex) wordLeft(offset int) int {
+	for offset > 0 && classOf(li.text[offset-1]) == spaceClass {
+		offset--
+	}
+	if offset == 0 {
+		return 0
+	}
+	class := classOf(li.text[offset-1])
+	for offset > 0 && classOf(li.text[offset-1]) == class {
+		offset--
+	}
+	return offset
+}
+
+// wordAt returns the word around an offset, or an empty range when there
+// is no word character on either side.
+func (li *lineIndex) wordAt(offset int) (int, int) {
+	start, end := offset, offset
+	for start > 0 && classOf(li.text[start-1]) == wordClass {
+		start--
+	}
+	for end < len(li.text) && classOf(li.text[end]) == wordClass {
+		end++
+	}
+	return start, end
+}
+
+// vimClass is the class of a character for Vim's word motions. Big words
+// (W, B and E) are runs of anything but blanks.
+func vimClass(ch rune, big bool) charClass {
+	class := classOf(ch)
+	if big && class == punctClass {
+		return wordClass
+	}
+	return class
+}
+
+// nextWordStart returns the offset of the start of the next word.
+func (li *lineIndex) nextWordStart(offset int, big bool) int {
+	if offset < len(li.text) {
+		class := vimClass(li.text[offset], big)
+		for class != spaceClass && offset < len(li.text) && vimClass(li.text[offset], big) == class {
+			offset++
+		}
+	}
+	for offset < len(li.text) && vimClass(li.text[offset], big) == spaceClass {
+		offset++
+	}
+	return offset
+}
+
+// nextWordEnd returns the offset of the last character of the word after
+// the offset.
+func (li *lineIndex) nextWordEnd(offset int, big bool) int {
+	offset++
+	for offset < len(li.text) && vimClass(li.text[offset], big) == spaceClass {
+		offset++
+	}
+	if offset >= len(li.text) {
+		return max(len(li.text)-1, 0)
+	}
+	class := vimClass(li.text[offset], big)
+	for offset+1 < len(li.text) && vimClass(li.text[offset+1], big) == class {
+		offset++
+	}
+	return offset
+}
+
+// prevWordStart returns the offset of the start of the word before the
+// offset.
+func (li *lineIndex) prevWordStart(offset int, big bool) int {
+	for offset > 0 && vimClass(li.text[offset-1], big) == spaceClass {
+		offset--
+	}
+	if offset == 0 {
+		return 0
+	}
+	class := vimClass(li.text[offset-1], big)
+	for offset > 0 && vimClass(li.text[offset-1], big) == class {
+		offset--
+	}
+	return offset
+}
+
+var bracketPairs = map[rune]rune{'(': ')', '[': ']', '{': '}', ')': '(', ']': '[', '}': '{'}
+
+// matchingBracket finds the partner of the bracket after the offset, or
+// else of the bracket before it. It returns the offset of the partner.
+func (li *lineIndex) matchingBracket(offset int) (int, bool) {
+	for _, at := range []int{offset, offset - 1} {
+		if at < 0 || at >= len(li.text) {
+			continue
+		}
+		open := li.text[at]
+		partner, isBracket := bracketPairs[open]
+		if !isBracket {
+			continue
+		}
+		step := 1
+		if open == ')' || open == ']' || open == '}' {
+			step = -1
+		}
+		depth := 0
+		for i := at; i >= 0 && i < len(li.text); i += step {
+			switch li.text[i] {
+			case open:
+				depth++
+			case partner:
+				depth--
+				if depth == 0 {
+					return i, true
+				}
+			}
+		}
+		return 0, false
+	}
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package editor
+
+// goalColumn is the display column vertical moves try to return to, so
+// passing through a short line does not lose the column.
+type goalColumn struct {
+	cells int
+	set   bool
+	keep  bool // the last move was vertical, keep the goal
+}
+
+// moveVertical returns a move of the cursor by delta lines that keeps the
+// goal column. Moving past the first or last line goes to its start or end.
+func (e *Editor) moveVertical(delta int) func() {
+	return func() {
+		lines := newLineIndex(e.RGA.GetText())
+		line, col := lines.position(e.RGA.ConvertCursior(e.RGA.CursorPosition))
+		if !e.goal.set {
+			e.goal = goalColumn{cells: displayWidth(lines.line(line)[:col], e.Indent.TabWidth), set: true}
+		}
+		e.goal.keep = true
+
+		target := line + delta
+		switch {
+		case target < 0:
+			e.RGA.SetCursor(0)
+		case target >= lines.lineCount():
+			e.RGA.SetCursor(len(lines.text))
+		default:
+			e.RGA.SetCursor(lines.offset(target, columnAtWidth(lines.line(target), e.goal.cells, e.Indent.TabWidth)))
+		}
+	}
+}
+
+// resetGoal forgets the goal column unless the last move was vertical.
+func (e *Editor) resetGoal() {
+	if !e.goal.keep {
+		e.goal = goalColumn{}
+	}
+	e.goal.keep = false
+}
+
+// MoveCursorHome goes to the first non-blank character of the line, or to
+// the very start when the cursor is already there.
+func (e *Editor) MoveCursorHome() {
+	e.moveCursor(e.cursorToLineStart, false)
+}
+
+func (e *Editor) MoveCursorEnd() {
+	e.moveCursor(e.cursorToLineEnd, false)
+}
+
+func (e *Editor) MoveWordLeft() {
+	e.moveCursor(e.cursorWordLeft, false)
+}
+
+func (e *Editor) MoveWordRight() {
+	e.moveCursor(e.cursorWordRight, false)
+}
+
+func (e *Editor) MoveDocumentStart() {
+	e.moveCursor(func() { e.RGA.SetCursor(0) }, false)
+}
+
+func (e *Editor) MoveDocumentEnd() {
+	e.moveCursor(e.cursorToDocumentEnd, false)
+}
+
+func (e *Editor) SelectWordLeft() {
+	e.moveCursor(e.cursorWordLeft, true)
+}
+
+func (e *Editor) SelectWordRight() {
+	e.moveCursor(e.cursorWordRight, true)
+}
+
+func (e *Editor) SelectDocumentStart() {
+	e.moveCursor(func() { e.RGA.SetCursor(0) }, true)
+}
+
+func (e *Editor) SelectDocumentEnd() {
+	e.moveCursor(e.cursorToDocumentEnd, true)
+}
+
+// GoToLine moves the cursor to a 1-based line and column.
+func (e *Editor) GoToLine(line int, column int) {
+	e.jumpTo(line-1, column-1)
+}
+
+// JumpToMatchingBracket moves the cursor to the partner of the bracket next
+// to it.
+func (e *Editor) JumpToMatchingBracket() {
+	lines := newLineIndex(e.RGA.GetText())
+	offset, ok := lines.matchingBracket(e.RGA.ConvertCursior(e.RGA.CursorPosition))
+	if !ok {
+		return
+	}
+	e.moveCursor(func() { e.RGA.SetCursor(offset) }, false)
+}
+
+func (e *Editor) cursorWordLeft() {
+	lines := newLineIndex(e.RGA.GetText())
+	e.RGA.SetCursor(lines.wordLeft(e.RGA.ConvertCursior(e.RGA.CursorPosition)))
+}
+
+func (e *Editor) cursorWordRight() {
+	lines := newLineIndex(e.RGA.GetText())
+	e.RGA.SetCursor(lines.wordRight(e.RGA.ConvertCursior(e.RGA.CursorPosition)))
+}
+
+func (e *Editor) cursorToDocumentEnd() {
+	e.RGA.CursorPosition = len(e.RGA.Elements)
+}
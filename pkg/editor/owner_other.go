@@ -0,0 +1,8 @@
+//go:build !unix
+
+package editor
+
+import "os"
+
+// keepOwner does nothing where files have no unix owner.
+func keepOwner(f *os.File, info os.FileInfo) {}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package editor
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives a replacement file the owner and group of the file it
+// replaces.
+func keepOwner(f *os.File, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		f.Chown(int(stat.Uid), int(stat.Gid))
+	}
+}
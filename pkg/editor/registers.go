@@ -0,0 +1,68 @@
+package editor
+
+import (
+	"edigo/pkg/clipboard"
+	"strings"
+	"unicode"
+)
+
+// register holds yanked or deleted text. Linewise text always ends with a
+// line break and is put on lines of its own.
+type register struct {
+	text     string
+	linewise bool
+}
+
+// registers follow Vim: " is the unnamed register, 0 the last yank, 1 to 9
+// the last deletes of whole lines, - the last small delete, a to z are named
+// (A to Z append), + and * are the system clipboard and _ discards.
+type registers map[rune]register
+
+func validRegister(name rune) bool {
+	return strings.ContainsRune(`"0123456789-_+*`, name) || (name < unicode.MaxASCII && unicode.IsLetter(name))
+}
+
+// store keeps text that was yanked or deleted into the named register, or
+// the default ones when name is 0.
+func (r registers) store(name rune, text string, linewise bool, yank bool) {
+	value := register{text: text, linewise: linewise}
+	switch {
+	case name == '_':
+		return
+	case name == '+' || name == '*':
+		clipboard.Write(text)
+	case name >= 'A' && name <= 'Z':
+		lower := unicode.ToLower(name)
+		previous := r[lower]
+		if previous.linewise && !linewise {
+			text += "\n"
+		}
+		value = register{text: previous.text + text, linewise: previous.linewise || linewise}
+		r[lower] = value
+	case name != 0 && name != '"':
+		r[name] = value
+	case yank:
+		r['0'] = value
+	case linewise || strings.Contains(text, "\n"):
+		for i := '9'; i > '1'; i-- {
+			r[i] = r[i-1]
+		}
+		r['1'] = value
+	default:
+		r['-'] = value
+	}
+	r['"'] = value
+}
+
+func (r registers) load(name rune) register {
+	switch {
+	case name == 0:
+		name = '"'
+	case name == '+' || name == '*':
+		text := clipboard.Read()
+		return register{text: text, linewise: strings.HasSuffix(text, "\n")}
+	case name >= 'A' && name <= 'Z':
+		name = unicode.ToLower(name)
+	}
+	return r[name]
+}
@@ -0,0 +1,149 @@
+package editor
+
+import (
+	"edigo/pkg/editorconfig"
+	"edigo/pkg/network"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+)
+
+// SaveRequest is a guest asking the host to write a session file to disk.
+type SaveRequest struct {
+	Sender   string
+	Username string
+	Document string
+	conn     net.Conn
+}
+
+type saveRequests struct {
+	pending []SaveRequest
+	mu      sync.Mutex
+}
+
+// SaveLocalCopy writes the open document to a path of our choosing. It works
+// for guests, who cannot save the host's file. A file that is already there
+// is only replaced with overwrite set, otherwise the error is fs.ErrExist.
+func (e *Editor) SaveLocalCopy(path string, overwrite bool) error {
+	if path == "" {
+		return fmt.Errorf("no file name given")
+	}
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return fmt.Errorf("%s: %w", path, fs.ErrExist)
+	}
+	data, err := e.EncodedDocument()
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, data, 0644, "")
+}
+
+// SaveAs writes the open file under a new name and keeps editing it there.
+// The tab, its panes and its sharing move along, guests and the session
+// announcement see the new name. The .editorconfig settings of the new name
+// apply from then on. A file that is already there is only replaced with
+// overwrite set, otherwise the error is fs.ErrExist.
+func (e *Editor) SaveAs(file string, overwrite bool) error {
+	if e.Network.Host != nil {
+		return fmt.Errorf("guests cannot rename the host's files, use Save Local Copy As")
+	}
+	if file == "" {
+		return fmt.Errorf("no file name given")
+	}
+	path, err := e.bufferPath(file)
+	if err != nil {
+		return err
+	}
+	if path == e.Document {
+		return e.Save(path)
+	}
+	e.docMu.Lock()
+	_, loaded := e.documents[path]
+	e.docMu.Unlock()
+	if loaded || e.isBuffer(path) || e.IsShared(path) {
+		return fmt.Errorf("%s is already open", path)
+	}
+	if _, err := os.Stat(file); err == nil && !overwrite {
+		return fmt.Errorf("%s: %w", file, fs.ErrExist)
+	}
+
+	// Line endings and charset the new place has no say on stay as they were.
+	format, _ := editorconfig.Resolve(file)
+	if format.EndOfLine == "" {
+		format.EndOfLine = e.Format.EndOfLine
+	}
+	if format.Charset == "" {
+		format.Charset = e.Format.Charset
+	}
+	data, err := format.Encode(e.RenderDocumentWithoutLineNumbers())
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(file, data, 0644, ""); err != nil {
+		return err
+	}
+	// renameBuffers sets up the syntax of the new name, with this format.
+	e.Format = format
+	e.renameBuffers(e.Document, path)
+	e.docMu.Lock()
+	e.recordDisk(path, file)
+	e.docMu.Unlock()
+	e.markSaved(path)
+	if e.Network.IsHost && e.IsShared(path) {
+		e.sendMessage(network.Message{Type: network.FormatMessage, Document: path, Format: format})
+	}
+	return nil
+}
+
+// RequestHostSave asks the host to save the canonical version of the open file.
+func (e *Editor) RequestHostSave() {
+	if e.Network.Host == nil {
+		e.Error = "Not connected to a session"
+		return
+	}
+	e.sendMessage(network.Message{Type: network.SaveRequestMessage, Document: e.Document})
+	e.Error = "Asked the host to save " + e.Document
+}
+
+// PendingSaveRequest pops the oldest save request guests sent to the host.
+func (e *Editor) PendingSaveRequest() (SaveRequest, bool) {
+	e.saveRequests.mu.Lock()
+	defer e.saveRequests.mu.Unlock()
+
+	if len(e.saveRequests.pending) == 0 {
+		return SaveRequest{}, false
+	}
+	request := e.saveRequests.pending[0]
+	e.saveRequests.pending = e.saveRequests.pending[1:]
+	return request, true
+}
+
+// DeclineSave tells the guest that the host did not save.
+func (e *Editor) DeclineSave(request SaveRequest) {
+	if request.conn != nil {
+		e.Network.SendMessage(network.Message{Type: network.SaveDeclinedMessage, Document: request.Document}, request.conn)
+	}
+}
+
+// NotifySaved lets every guest know that the host saved a session file.
+func (e *Editor) NotifySaved(document string) {
+	if !e.Network.IsHost {
+		return
+	}
+	e.sendMessage(network.Message{Type: network.SavedMessage, Document: document})
+}
+
+func (e *Editor) receiveSaveRequest(conn net.Conn, msg network.Message) {
+	request := SaveRequest{
+		Sender:   msg.Sender,
+		Username: e.remoteUser(msg.Sender).Username,
+		Document: msg.Document,
+		conn:     conn,
+	}
+
+	e.saveRequests.mu.Lock()
+	e.saveRequests.pending = append(e.saveRequests.pending, request)
+	e.saveRequests.mu.Unlock()
+}
@@ -0,0 +1,115 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	scrollOff           = 3 // lines kept visible above and below the cursor
+	horizontalScrollOff = 5 // cells kept visible left and right of the cursor
+	wheelLines          = 3
+	wheelColumns        = 8
+	editorHeaderHeight  = 2 // header line and its border above the text
+)
+
+// ScrollToCursor scrolls the viewport just enough to keep the local cursor
+// inside the scroll-off margins.
+func (e *Editor) ScrollToCursor() {
+	lines := newLineIndex(e.RGA.GetText())
+	line, col := lines.position(e.RGA.ConvertCursior(e.RGA.CursorPosition))
+	height := e.visibleLines()
+
+	margin := scrollOff
+	if margin > (height-1)/2 {
+		margin = (height - 1) / 2
+	}
+	top := e.ScrollTop
+	if line < top+margin {
+		top = line - margin
+	}
+	if line > top+height-1-margin {
+		top = line - height + 1 + margin
+	}
+	e.SetScrollTop(top)
+
+	width := e.textWidth()
+	cell := displayWidth(lines.line(line)[:col], e.Indent.TabWidth)
+	hMargin := horizontalScrollOff
+	if hMargin > (width-1)/2 {
+		hMargin = (width - 1) / 2
+	}
+	if cell < e.ScrollLeft+hMargin {
+		e.ScrollLeft = cell - hMargin
+	}
+	if cell > e.ScrollLeft+width-1-hMargin {
+		e.ScrollLeft = cell - width + 1 + hMargin
+	}
+	if e.ScrollLeft < 0 {
+		e.ScrollLeft = 0
+	}
+}
+
+// ScrollBy scrolls the viewport without moving the cursor, like the mouse wheel.
+func (e *Editor) ScrollBy(lines int) {
+	e.StopFollowing()
+	e.SetScrollTop(e.ScrollTop + lines)
+}
+
+func (e *Editor) ScrollHorizontally(cells int) {
+	e.ScrollLeft += cells
+	if e.ScrollLeft < 0 {
+		e.ScrollLeft = 0
+	}
+}
+
+func (e *Editor) ScrollWheel(up bool) {
+	if up {
+		e.ScrollBy(-wheelLines)
+	} else {
+		e.ScrollBy(wheelLines)
+	}
+}
+
+func (e *Editor) ScrollWheelHorizontal(left bool) {
+	if left {
+		e.ScrollHorizontally(-wheelColumns)
+	} else {
+		e.ScrollHorizontally(wheelColumns)
+	}
+}
+
+func (e *Editor) PageUp() {
+	e.movePage(-1)
+}
+
+func (e *Editor) PageDown() {
+	e.movePage(1)
+}
+
+// movePage moves the cursor and the viewport by one screen, keeping the
+// cursor at the same place on screen.
+func (e *Editor) movePage(direction int) {
+	height := e.visibleLines()
+	e.SetScrollTop(e.ScrollTop + direction*height)
+	e.moveCursor(e.moveVertical(direction*height), false)
+}
+
+// gutterWidth is the width of the line number column including its padding.
+func (e *Editor) gutterWidth() int {
+	lineCount := strings.Count(e.RGA.GetText(), "\n") + 1
+	lineNumberWidth := len(fmt.Sprintf("%d", lineCount))
+	return lipgloss.Width(e.Theme.RenderLineNumber(strings.Repeat("9", lineNumberWidth), lineNumberWidth))
+}
+
+// textWidth is the number of cells available for text, keeping one cell
+// for the cursor drawn at the end of a line.
+func (e *Editor) textWidth() int {
+	width := e.Viewport.Width - e.gutterWidth() - 1
+	if width < 1 {
+		return 1
+	}
+	return width
+}
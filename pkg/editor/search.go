@@ -0,0 +1,209 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"regexp"
+	"unicode/utf8"
+)
+
+type SearchOptions struct {
+	Regex         bool
+	CaseSensitive bool
+	WholeWord     bool
+}
+
+// Search is the state of the find bar. Matches are recomputed from the text
+// on every use, so they follow remote edits.
+type Search struct {
+	Query   string
+	Options SearchOptions
+	Active  bool
+	Current int // visible offset of the current match
+	Err     error
+}
+
+type match struct {
+	Start    int
+	End      int
+	submatch []int // byte offsets of the groups in the searched text
+}
+
+func (o SearchOptions) compile(query string) (*regexp.Regexp, error) {
+	pattern := query
+	if !o.Regex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if o.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !o.CaseSensitive {
+		pattern = `(?i)` + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// SetSearch updates the query and jumps to the first match at or after
+// where the search started.
+func (e *Editor) SetSearch(query string, options SearchOptions) {
+	if !e.Search.Active {
+		e.Search.Current = e.RGA.ConvertCursior(e.RGA.CursorPosition)
+	}
+	e.Search = Search{Query: query, Options: options, Active: true, Current: e.Search.Current}
+
+	matches := e.searchMatches()
+	if len(matches) == 0 {
+		return
+	}
+	for _, m := range matches {
+		if m.Start >= e.Search.Current {
+			e.jumpToMatch(m)
+			return
+		}
+	}
+	e.jumpToMatch(matches[0])
+}
+
+func (e *Editor) ClearSearch() {
+	e.Search = Search{}
+}
+
+func (e *Editor) NextMatch() {
+	matches := e.searchMatches()
+	if len(matches) == 0 {
+		return
+	}
+	for _, m := range matches {
+		if m.Start > e.Search.Current {
+			e.jumpToMatch(m)
+			return
+		}
+	}
+	e.jumpToMatch(matches[0])
+}
+
+func (e *Editor) PrevMatch() {
+	matches := e.searchMatches()
+	if len(matches) == 0 {
+		return
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		if matches[i].Start < e.Search.Current {
+			e.jumpToMatch(matches[i])
+			return
+		}
+	}
+	e.jumpToMatch(matches[len(matches)-1])
+}
+
+// MatchCount returns the number of matches and the 1-based number of the
+// current one, 0 if the cursor is not on a match.
+func (e *Editor) MatchCount() (int, int) {
+	matches := e.searchMatches()
+	for i, m := range matches {
+		if m.Start == e.Search.Current {
+			return len(matches), i + 1
+		}
+	}
+	return len(matches), 0
+}
+
+// ReplaceCurrent replaces the current match and moves on to the next one.
+func (e *Editor) ReplaceCurrent(replacement string) {
+	re, content, matches := e.compiledMatches()
+	for _, m := range matches {
+		if m.Start == e.Search.Current {
+			ops := e.replaceMatch(re, content, m, replacement)
+			e.sendBatch(ops)
+			e.Search.Current = m.Start + utf8.RuneCountInString(e.expand(re, content, m, replacement))
+			e.updateLocalCursor()
+			e.NextMatch()
+			return
+		}
+	}
+	e.NextMatch()
+}
+
+// ReplaceAll replaces every match and sends the result as one change.
+func (e *Editor) ReplaceAll(replacement string) int {
+	re, content, matches := e.compiledMatches()
+	if len(matches) == 0 {
+		return 0
+	}
+
+	cursor := e.RGA.ConvertCursior(e.RGA.CursorPosition)
+	ops := []crdt.Operation{}
+	// Work backwards so earlier offsets stay valid.
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		ops = append(ops, e.replaceMatch(re, content, m, replacement)...)
+		if m.End <= cursor {
+			cursor += utf8.RuneCountInString(e.expand(re, content, m, replacement)) - (m.End - m.Start)
+		}
+	}
+	e.sendBatch(ops)
+	e.RGA.SetCursor(cursor)
+	e.Search.Current = cursor
+	e.updateLocalCursor()
+	return len(matches)
+}
+
+func (e *Editor) replaceMatch(re *regexp.Regexp, content string, m match, replacement string) []crdt.Operation {
+	e.ClearSelection()
+	ops := e.RGA.LocalDeleteRange(m.Start, m.End)
+	return append(ops, e.RGA.LocalInsertText(e.expand(re, content, m, replacement))...)
+}
+
+// expand resolves $1 style group references for regular expression searches.
+// The groups come from the search over the whole text, so anchors and
+// lookarounds see the same context they matched in.
+func (e *Editor) expand(re *regexp.Regexp, content string, m match, replacement string) string {
+	if !e.Search.Options.Regex {
+		return replacement
+	}
+	return string(re.ExpandString(nil, replacement, content, m.submatch))
+}
+
+func (e *Editor) jumpToMatch(m match) {
+	e.Search.Current = m.Start
+	e.ClearSelection()
+	e.goal = goalColumn{}
+	e.RGA.SetCursor(m.Start)
+	e.syncLocalCursor()
+	e.ScrollToCursor()
+}
+
+func (e *Editor) searchMatches() []match {
+	_, _, matches := e.compiledMatches()
+	return matches
+}
+
+func (e *Editor) compiledMatches() (*regexp.Regexp, string, []match) {
+	if !e.Search.Active || e.Search.Query == "" {
+		return nil, "", nil
+	}
+	re, err := e.Search.Options.compile(e.Search.Query)
+	e.Search.Err = err
+	if err != nil {
+		return nil, "", nil
+	}
+
+	content := e.RGA.GetText()
+
+	// Convert byte offsets from the regexp into rune offsets.
+	byteToRune := make([]int, len(content)+1)
+	r := 0
+	for i := range content {
+		byteToRune[i] = r
+		r++
+	}
+	byteToRune[len(content)] = r
+
+	matches := []match{}
+	for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		if loc[0] == loc[1] {
+			continue // empty matches cannot be highlighted or replaced
+		}
+		matches = append(matches, match{Start: byteToRune[loc[0]], End: byteToRune[loc[1]], submatch: loc})
+	}
+	return re, content, matches
+}
@@ -0,0 +1,284 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"sort"
+	"strings"
+)
+
+// Selection spans from an anchor to the local cursor. The anchor is stored
+// as the ID of the element just before it ("" for the start of the
+// document), so it keeps its place when collaborators edit around it.
+type Selection struct {
+	Anchor string
+	Active bool
+	Mark   bool // set by SetMark, cursor moves extend the selection
+}
+
+// anchorAt returns the anchor for the gap in front of an element index.
+func (e *Editor) anchorAt(position int) string {
+	if position <= 0 || position > len(e.RGA.Elements) {
+		return ""
+	}
+	return e.RGA.Elements[position-1].ID
+}
+
+// anchorOffset resolves an anchor to its visible offset.
+func (e *Editor) anchorOffset(anchor string) int {
+	if anchor == "" {
+		return 0
+	}
+	index := e.RGA.IndexOfID(anchor)
+	if index < 0 {
+		return 0
+	}
+	return e.RGA.ConvertCursior(index + 1)
+}
+
+func (e *Editor) startSelection() {
+	if e.Selection.Active {
+		return
+	}
+	e.Selection = Selection{Anchor: e.anchorAt(e.RGA.CursorPosition), Active: true}
+}
+
+func (e *Editor) ClearSelection() {
+	e.Selection = Selection{}
+}
+
+// SelectionRange returns the selected visible offsets, start before end.
+func (e *Editor) SelectionRange() (int, int, bool) {
+	if !e.Selection.Active {
+		return 0, 0, false
+	}
+	anchor := e.anchorOffset(e.Selection.Anchor)
+	head := e.RGA.ConvertCursior(e.RGA.CursorPosition)
+	if anchor == head {
+		return 0, 0, false
+	}
+	if anchor > head {
+		anchor, head = head, anchor
+	}
+	return anchor, head, true
+}
+
+func (e *Editor) HasSelection() bool {
+	_, _, ok := e.SelectionRange()
+	return ok
+}
+
+func (e *Editor) SelectedText() string {
+	start, end, ok := e.SelectionRange()
+	if !ok {
+		return ""
+	}
+	return string([]rune(e.RGA.GetText())[start:end])
+}
+
+// DeleteSelection removes the selected text and reports whether there was any.
+func (e *Editor) DeleteSelection() bool {
+	ops := e.deleteSelectionOps()
+	if len(ops) == 0 {
+		return false
+	}
+	e.sendBatch(ops)
+	e.updateLocalCursor()
+	return true
+}
+
+// deleteSelectionOps deletes the selection locally and returns the
+// operations, so callers can send them along with their own.
+func (e *Editor) deleteSelectionOps() []crdt.Operation {
+	start, end, ok := e.SelectionRange()
+	e.ClearSelection()
+	if !ok {
+		return nil
+	}
+	return e.RGA.LocalDeleteRange(start, end)
+}
+
+// Copy returns the selected text. The selections of several cursors are
+// joined by line breaks in the order of the document.
+func (e *Editor) Copy() string {
+	if len(e.cursors.carets) == 0 {
+		return e.SelectedText()
+	}
+	type part struct {
+		start int
+		text  string
+	}
+	parts := []part{}
+	if start, _, ok := e.SelectionRange(); ok {
+		parts = append(parts, part{start, e.SelectedText()})
+	}
+	text := []rune(e.RGA.GetText())
+	for _, c := range e.cursors.carets {
+		if start, end, ok := e.caretRange(c); ok {
+			parts = append(parts, part{start, string(text[start:end])})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start < parts[j].start })
+	texts := make([]string, len(parts))
+	for i, p := range parts {
+		texts[i] = p.text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Cut returns the selected text and deletes it at every cursor.
+func (e *Editor) Cut() string {
+	text := e.Copy()
+	e.forEachCursor(func() { e.DeleteSelection() })
+	return text
+}
+
+// Paste inserts text exactly as given, without the auto formatting typing
+// gets, and sends it as one change. With as many lines as cursors, each
+// cursor gets one line, in the order the cursors appear in the text.
+func (e *Editor) Paste(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if text == "" {
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(e.cursors.carets) == 0 || len(lines) != len(e.cursors.carets)+1 {
+		e.forEachCursor(func() { e.InsertText(text) })
+		return
+	}
+
+	// forEachCursor visits the local cursor first, then the others in the
+	// order they were added.
+	offsets := []int{e.cursorOffset()}
+	for _, c := range e.cursors.carets {
+		offsets = append(offsets, e.anchorOffset(c.head))
+	}
+	order := make([]int, len(offsets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return offsets[order[a]] < offsets[order[b]] })
+	parts := make([]string, len(lines))
+	for rank, i := range order {
+		parts[i] = lines[rank]
+	}
+
+	next := 0
+	e.forEachCursor(func() {
+		e.InsertText(parts[next])
+		next++
+	})
+}
+
+func (e *Editor) SelectLeft() {
+	e.moveCursor(e.RGA.MoveCursorLeft, true)
+}
+
+func (e *Editor) SelectRight() {
+	e.moveCursor(e.RGA.MoveCursorRight, true)
+}
+
+func (e *Editor) SelectUp() {
+	e.moveCursor(e.moveVertical(-1), true)
+}
+
+func (e *Editor) SelectDown() {
+	e.moveCursor(e.moveVertical(1), true)
+}
+
+func (e *Editor) SelectToLineStart() {
+	e.moveCursor(e.cursorToLineStart, true)
+}
+
+func (e *Editor) SelectToLineEnd() {
+	e.moveCursor(e.cursorToLineEnd, true)
+}
+
+func (e *Editor) SelectAll() {
+	e.DropCursors()
+	e.ClearSelection()
+	e.RGA.SetCursor(0)
+	e.startSelection()
+	e.RGA.CursorPosition = len(e.RGA.Elements)
+	e.updateLocalCursor()
+}
+
+// cursorToLineStart toggles between the first non-blank character of the
+// line and its start.
+func (e *Editor) cursorToLineStart() {
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.RGA.ConvertCursior(e.RGA.CursorPosition)
+	line, _ := lines.position(offset)
+	target := lines.firstNonBlank(line)
+	if offset == target {
+		target = lines.lineStart(line)
+	}
+	e.RGA.SetCursor(target)
+}
+
+func (e *Editor) cursorToLineEnd() {
+	lines := newLineIndex(e.RGA.GetText())
+	line, _ := lines.position(e.RGA.ConvertCursior(e.RGA.CursorPosition))
+	e.RGA.SetCursor(lines.lineEnd(line))
+}
+
+func (e *Editor) highlights() []highlight {
+	highlights := []highlight{}
+	if start, end, ok := e.visualRange(); ok {
+		highlights = append(highlights, highlight{Start: start, End: end, Style: e.Theme.SelectionStyle})
+	}
+	if start, end, ok := e.SelectionRange(); ok {
+		highlights = append(highlights, highlight{Start: start, End: end, Style: e.Theme.SelectionStyle})
+	}
+	for _, c := range e.cursors.carets {
+		if start, end, ok := e.caretRange(c); ok {
+			highlights = append(highlights, highlight{Start: start, End: end, Style: e.Theme.SelectionStyle})
+		}
+	}
+	for _, m := range e.searchMatches() {
+		style := e.Theme.SearchMatchStyle
+		if m.Start == e.Search.Current {
+			style = e.Theme.CurrentMatchStyle
+		}
+		highlights = append(highlights, highlight{Start: m.Start, End: m.End, Style: style})
+	}
+	return highlights
+}
+
+// offsetAtScreen maps a cell of the rendered content to a visible offset.
+func (e *Editor) offsetAtScreen(x int, y int) int {
+	lines := newLineIndex(e.RGA.GetText())
+	line := e.ScrollTop + y - editorHeaderHeight
+	if line >= lines.lineCount() {
+		return len(lines.text)
+	}
+	cell := x - e.gutterWidth() + e.ScrollLeft
+	if cell < 0 {
+		cell = 0
+	}
+	return lines.offset(line, columnAtWidth(lines.line(line), cell, e.Indent.TabWidth))
+}
+
+// MousePress places the cursor under the mouse and starts a new selection
+// that MouseDrag extends.
+func (e *Editor) MousePress(x int, y int) {
+	e.DropCursors()
+	e.ClearSelection()
+	e.RGA.SetCursor(e.offsetAtScreen(x, y))
+	e.startSelection()
+	e.updateLocalCursor()
+}
+
+func (e *Editor) MouseDrag(x int, y int) {
+	if !e.Selection.Active {
+		e.startSelection()
+	}
+	e.RGA.SetCursor(e.offsetAtScreen(x, y))
+	e.updateLocalCursor()
+}
+
+func (e *Editor) MouseRelease() {
+	if !e.HasSelection() {
+		e.ClearSelection()
+	}
+}
@@ -0,0 +1,110 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"edigo/pkg/highlighter"
+	"path/filepath"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// View is what a pane of the window shows: a buffer, the local cursor in it
+// and where it is scrolled to. Only the focused pane is live in the editor,
+// the others keep their View until they get the focus back.
+type View struct {
+	Document   string
+	cursor     caret
+	ScrollTop  int
+	ScrollLeft int
+}
+
+// CurrentView captures the focused pane.
+func (e *Editor) CurrentView() View {
+	return View{Document: e.Document, cursor: e.saveCaret(), ScrollTop: e.ScrollTop, ScrollLeft: e.ScrollLeft}
+}
+
+// ShowView gives the focus to a pane, opening its buffer if another one is
+// open. Guests wait for the host to send the file, the cursor then starts
+// where they left the file before.
+func (e *Editor) ShowView(v View) {
+	v.Document = e.currentPath(v.Document)
+	if v.Document != e.Document {
+		e.OpenDocument(v.Document)
+		if v.Document != e.Document {
+			return
+		}
+	}
+	e.DropCursors()
+	e.Visual = Visual{}
+	e.restoreCaret(v.cursor)
+	e.ScrollTop = v.ScrollTop
+	e.ScrollLeft = v.ScrollLeft
+	e.syncLocalCursor()
+	e.SendViewportUpdate()
+}
+
+// RenderView draws a pane without the focus. It renders a copy of the
+// buffer so the focused pane's cursor and scroll position stay untouched,
+// remote cursors show up in every pane that has them in sight.
+func (e *Editor) RenderView(v View, width int, height int) string {
+	e.docMu.Lock()
+	v.Document = e.currentPath(v.Document)
+	rga, format := e.RGA, e.Format
+	if v.Document != e.Document {
+		doc, exists := e.documents[v.Document]
+		if !exists {
+			e.docMu.Unlock()
+			return lipgloss.NewStyle().Width(width).Height(height).Render(v.Document + " is not open")
+		}
+		rga, format = doc.RGA, doc.Format
+	}
+	e.docMu.Unlock()
+
+	crdt.InsertM.Lock()
+	snapshot := rga.Copy()
+	crdt.InsertM.Unlock()
+
+	pane := &Editor{
+		RGA:             snapshot,
+		Viewport:        viewport.New(width, height),
+		Network:         e.Network,
+		FilePath:        e.documentDisplayPath(v.Document),
+		Theme:           e.Theme,
+		LocalCursor:     e.LocalCursor,
+		RemoteCursors:   e.RemoteUsers(),
+		IsSharedSession: e.IsSharedSession,
+		ScrollTop:       v.ScrollTop,
+		ScrollLeft:      v.ScrollLeft,
+		Format:          format,
+		Search:          e.Search,
+		Document:        v.Document,
+	}
+	pane.useSyntax(e.viewSyntax(filepath.Ext(v.Document)))
+	pane.restoreCaret(v.cursor)
+	pane.LocalCursor.Position = pane.RGA.CursorPosition
+	return pane.RenderContent()
+}
+
+// viewSyntax returns the highlighter for a pane, building one per file
+// extension only once since panes are drawn on every frame.
+func (e *Editor) viewSyntax(fileExt string) highlighter.SyntaxDefinition {
+	if e.paneSyntax == nil {
+		e.paneSyntax = make(map[string]highlighter.SyntaxDefinition)
+	}
+	syntax, exists := e.paneSyntax[fileExt]
+	if !exists {
+		syntax = *highlighter.GetSyntaxDefiniton(fileExt)
+		e.paneSyntax[fileExt] = syntax
+	}
+	return syntax
+}
+
+// documentDisplayPath is the path shown for a session file. Guests only
+// know the path relative to the host's session root.
+func (e *Editor) documentDisplayPath(path string) string {
+	if e.Network.Host != nil {
+		return path
+	}
+	return e.documentFilePath(path)
+}
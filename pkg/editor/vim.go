@@ -0,0 +1,918 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type VimMode int
+
+const (
+	VimNormal VimMode = iota
+	VimInsert
+	VimVisual
+	VimVisualLine
+	VimCommandLine
+)
+
+// Visual is the selection of Vim's visual mode. The anchor is stored like
+// the one of Selection, but the range includes the character under the
+// cursor, or whole lines in line mode.
+type Visual struct {
+	Anchor string
+	Active bool
+	Lines  bool
+}
+
+// Vim is modal editing on top of the editor. Insert mode hands keys to the
+// input handler; the other modes read Vim commands.
+type Vim struct {
+	Mode        VimMode
+	CommandLine string // text typed after ":"
+	Message     string // error of the last command
+	handler     *InputHandler
+	editor      *Editor
+	pending     []string // keys of the command being typed
+	registers   registers
+	lastChange  vimChange
+	recording   bool         // the running insert belongs to lastChange
+	inserted    []tea.KeyMsg // keys typed in the running insert
+	insert      vimInsert    // how the running insert was entered
+	replaying   bool
+}
+
+// vimCommand is a parsed normal or visual mode command: an operator with
+// a motion, a motion alone, or an action.
+type vimCommand struct {
+	register    rune
+	count       int
+	operator    string
+	motionCount int
+	motion      string // the operator again works on whole lines, as in dd
+	action      string
+	arg         string // character of f, t, F, T and r
+}
+
+// vimChange is the last change for dot-repeat, with the keys typed in
+// insert mode if it started one.
+type vimChange struct {
+	command  vimCommand
+	inserted []tea.KeyMsg
+}
+
+type vimInsert struct {
+	action string
+	count  int
+}
+
+type vimParse int
+
+const (
+	vimComplete vimParse = iota
+	vimPending
+	vimInvalid
+)
+
+var (
+	vimOperators   = map[string]bool{"d": true, "c": true, "y": true, ">": true, "<": true}
+	vimCharMotions = map[string]bool{"f": true, "F": true, "t": true, "T": true}
+	vimMotions     = map[string]bool{
+		"h": true, "j": true, "k": true, "l": true, "w": true, "b": true, "e": true, "W": true, "B": true, "E": true,
+		"0": true, "^": true, "$": true, "G": true, "%": true, "+": true, "-": true,
+		"left": true, "right": true, "up": true, "down": true, "home": true, "end": true, "backspace": true, "enter": true, " ": true,
+	}
+	vimActions = map[string]bool{
+		"i": true, "a": true, "I": true, "A": true, "o": true, "O": true, "x": true, "X": true, "D": true, "C": true,
+		"s": true, "S": true, "Y": true, "p": true, "P": true, "J": true, "v": true, "V": true, ":": true, ".": true,
+	}
+	// vimKeys are the keys other than characters Vim reads in normal mode.
+	vimKeys = map[string]bool{"left": true, "right": true, "up": true, "down": true, "home": true, "end": true, "backspace": true, "enter": true}
+)
+
+// vimChanges are the commands dot-repeat remembers.
+var vimChanges = map[string]bool{
+	"d": true, "c": true, ">": true, "<": true, "i": true, "a": true, "I": true, "A": true, "o": true, "O": true,
+	"x": true, "X": true, "D": true, "C": true, "s": true, "S": true, "p": true, "P": true, "J": true, "r": true,
+}
+
+func NewVim(handler *InputHandler) *Vim {
+	return &Vim{
+		handler:   handler,
+		editor:    handler.Editor,
+		registers: registers{},
+	}
+}
+
+// SetVim turns Vim mode on or off.
+func (ih *InputHandler) SetVim(on bool) {
+	ih.Editor.Visual = Visual{}
+	ih.Vim = nil
+	if on {
+		ih.Vim = NewVim(ih)
+	}
+}
+
+// HandleKey runs a key in the current mode. It reports whether Vim used the
+// key, and returns the commands of the command line for the UI to run:
+// "save", "quit" and "quit!", the buffer and pane commands of the registry,
+// "edit <file>" and "save-as <file>".
+func (v *Vim) HandleKey(msg tea.KeyMsg) ([]string, bool) {
+	if v.visual() && !v.editor.Visual.Active {
+		// Switching documents drops the selection.
+		v.Mode = VimNormal
+	}
+	v.Message = ""
+
+	switch v.Mode {
+	case VimInsert:
+		if msg.Type == tea.KeyEsc {
+			v.exitInsert()
+			return nil, true
+		}
+		v.inserted = append(v.inserted, msg)
+		return nil, false
+	case VimCommandLine:
+		return v.commandLineKey(msg), true
+	}
+
+	if msg.Paste || msg.Alt {
+		return nil, false
+	}
+	if msg.Type == tea.KeyEsc {
+		if len(v.pending) == 0 && !v.visual() {
+			return nil, false
+		}
+		v.pending = nil
+		v.exitVisual()
+		return nil, true
+	}
+	key := msg.String()
+	if msg.Type != tea.KeyRunes && msg.Type != tea.KeySpace && !vimKeys[key] {
+		return nil, false
+	}
+
+	v.pending = append(v.pending, key)
+	cmd, state := parseVim(v.pending, v.visual())
+	switch state {
+	case vimPending:
+		return nil, true
+	case vimInvalid:
+		v.pending = nil
+		return nil, true
+	}
+	v.pending = nil
+	v.execute(cmd)
+	return nil, true
+}
+
+// Status is the mode for the status bar, with the keys typed so far.
+func (v *Vim) Status() string {
+	status := "-- NORMAL --"
+	switch v.Mode {
+	case VimInsert:
+		status = "-- INSERT --"
+	case VimVisual:
+		status = "-- VISUAL --"
+	case VimVisualLine:
+		status = "-- VISUAL LINE --"
+	case VimCommandLine:
+		return ":" + v.CommandLine
+	}
+	if len(v.pending) > 0 {
+		status += "  " + strings.Join(v.pending, "")
+	}
+	if v.Message != "" {
+		status += "  " + v.Message
+	}
+	return status
+}
+
+func (v *Vim) visual() bool {
+	return v.Mode == VimVisual || v.Mode == VimVisualLine
+}
+
+// parseVim reads a command from the keys typed so far:
+// ["x][count]operator[count]motion, ["x][count]motion or ["x][count]action.
+// In visual mode operators need no motion.
+func parseVim(keys []string, visual bool) (vimCommand, vimParse) {
+	cmd := vimCommand{}
+	i := 0
+	if keys[0] == `"` {
+		if len(keys) < 2 {
+			return cmd, vimPending
+		}
+		name := []rune(keys[1])
+		if len(name) != 1 || !validRegister(name[0]) {
+			return cmd, vimInvalid
+		}
+		cmd.register = name[0]
+		i = 2
+	}
+
+	cmd.count, i = parseCount(keys, i)
+	if i >= len(keys) {
+		return cmd, vimPending
+	}
+	k := keys[i]
+	i++
+	switch {
+	case vimOperators[k]:
+		cmd.operator = k
+		if visual {
+			return cmd, vimComplete
+		}
+	case vimActions[k]:
+		cmd.action = k
+		return cmd, vimComplete
+	case k == "r":
+		cmd.action = k
+		return parseArg(cmd, keys, i)
+	default:
+		return parseMotion(cmd, keys, i-1)
+	}
+
+	cmd.motionCount, i = parseCount(keys, i)
+	if i >= len(keys) {
+		return cmd, vimPending
+	}
+	if keys[i] == cmd.operator {
+		cmd.motion = cmd.operator
+		return cmd, vimComplete
+	}
+	return parseMotion(cmd, keys, i)
+}
+
+func parseMotion(cmd vimCommand, keys []string, i int) (vimCommand, vimParse) {
+	k := keys[i]
+	switch {
+	case k == "g":
+		if i+1 >= len(keys) {
+			return cmd, vimPending
+		}
+		if keys[i+1] != "g" {
+			return cmd, vimInvalid
+		}
+		cmd.motion = "gg"
+	case vimCharMotions[k]:
+		cmd.motion = k
+		return parseArg(cmd, keys, i+1)
+	case vimMotions[k]:
+		cmd.motion = k
+	default:
+		return cmd, vimInvalid
+	}
+	return cmd, vimComplete
+}
+
+func parseArg(cmd vimCommand, keys []string, i int) (vimCommand, vimParse) {
+	if i >= len(keys) {
+		return cmd, vimPending
+	}
+	if len([]rune(keys[i])) != 1 {
+		return cmd, vimInvalid
+	}
+	cmd.arg = keys[i]
+	return cmd, vimComplete
+}
+
+// parseCount reads the digits at keys[i]. A leading 0 is a motion.
+func parseCount(keys []string, i int) (int, int) {
+	count := 0
+	for ; i < len(keys); i++ {
+		k := keys[i]
+		if len(k) != 1 || k[0] < '0' || k[0] > '9' || (k == "0" && count == 0) {
+			break
+		}
+		count = min(count*10+int(k[0]-'0'), 99999)
+	}
+	return count, i
+}
+
+func (c vimCommand) times() int {
+	return max(c.count, 1) * max(c.motionCount, 1)
+}
+
+func (c vimCommand) hasCount() bool {
+	return c.count > 0 || c.motionCount > 0
+}
+
+func (v *Vim) execute(cmd vimCommand) {
+	if v.visual() {
+		v.executeVisual(cmd)
+	} else {
+		if !v.replaying && vimChanges[cmd.operator+cmd.action] {
+			v.lastChange = vimChange{command: cmd}
+			v.recording = true
+		}
+		switch {
+		case cmd.operator != "":
+			v.applyOperator(cmd)
+		case cmd.motion != "":
+			v.move(cmd)
+		default:
+			v.action(cmd)
+		}
+	}
+	if v.Mode != VimInsert {
+		v.recording = false
+	}
+	if v.Mode != VimInsert && v.Mode != VimCommandLine {
+		v.clampCursor()
+	}
+}
+
+func (v *Vim) action(cmd vimCommand) {
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	line, col := lines.position(e.cursorOffset())
+	n := cmd.times()
+
+	// Shorthands for an operator with a motion.
+	shorthand := map[string][2]string{
+		"x": {"d", "l"}, "X": {"d", "h"}, "D": {"d", "$"}, "C": {"c", "$"},
+		"s": {"c", "l"}, "S": {"c", "c"}, "Y": {"y", "y"},
+	}
+	if short, ok := shorthand[cmd.action]; ok {
+		v.applyOperator(vimCommand{register: cmd.register, count: cmd.count, operator: short[0], motion: short[1]})
+		return
+	}
+
+	switch cmd.action {
+	case "i", "a", "I", "A", "o", "O":
+		switch cmd.action {
+		case "a":
+			if col < len(lines.line(line)) {
+				e.moveCursor(e.RGA.MoveCursorRight, false)
+			}
+		case "I":
+			e.moveCursor(func() { e.RGA.SetCursor(lines.firstNonBlank(line)) }, false)
+		case "A":
+			e.moveCursor(func() { e.RGA.SetCursor(lines.lineEnd(line)) }, false)
+		case "o":
+			e.moveCursor(func() { e.RGA.SetCursor(lines.lineEnd(line)) }, false)
+			e.NewLine()
+		case "O":
+			v.openLineAbove()
+		}
+		v.startInsert(cmd.action, n)
+	case "p", "P":
+		v.put(v.registers.load(cmd.register), cmd.action == "P", n)
+	case "J":
+		v.selectLines(line, line+max(n, 2)-1)
+		e.JoinLines()
+	case "r":
+		if col+n > len(lines.line(line)) {
+			return
+		}
+		offset := e.cursorOffset()
+		ops := e.RGA.LocalDeleteRange(offset, offset+n)
+		ops = append(ops, e.RGA.LocalInsertText(strings.Repeat(cmd.arg, n))...)
+		e.sendBatch(ops)
+		e.RGA.SetCursor(offset + n - 1)
+		e.updateLocalCursor()
+	case "v", "V":
+		e.ClearSelection()
+		e.Visual = Visual{Anchor: e.anchorAt(e.RGA.CursorPosition), Active: true, Lines: cmd.action == "V"}
+		v.Mode = VimVisual
+		if cmd.action == "V" {
+			v.Mode = VimVisualLine
+		}
+	case ":":
+		v.Mode = VimCommandLine
+		v.CommandLine = ""
+	case ".":
+		v.repeat(cmd.count)
+	}
+}
+
+// executeVisual runs a command on the visual selection. Motions extend it.
+func (v *Vim) executeVisual(cmd vimCommand) {
+	e := v.editor
+	if cmd.motion != "" {
+		v.move(cmd)
+		return
+	}
+
+	start, end, _ := e.visualRange()
+	lines := newLineIndex(e.RGA.GetText())
+	linewise := e.Visual.Lines
+	operator := cmd.operator
+	switch cmd.action {
+	case "x":
+		operator = "d"
+	case "X", "D":
+		operator, linewise = "d", true
+	case "s":
+		operator = "c"
+	case "S", "C":
+		operator, linewise = "c", true
+	case "Y":
+		operator, linewise = "y", true
+	case "J":
+		first, _ := lines.position(start)
+		last, _ := lines.position(end)
+		v.exitVisual()
+		v.selectLines(first, max(last, first+1))
+		e.JoinLines()
+		return
+	case "p", "P":
+		saved := v.registers.load(cmd.register)
+		v.exitVisual()
+		v.operate("d", 0, lines, start, end, linewise)
+		v.put(saved, true, 1)
+		return
+	case "o":
+		anchor := e.anchorOffset(e.Visual.Anchor)
+		e.Visual.Anchor = e.anchorAt(e.RGA.CursorPosition)
+		e.RGA.SetCursor(anchor)
+		e.updateLocalCursor()
+		return
+	case "v", "V":
+		if e.Visual.Lines == (cmd.action == "V") {
+			v.exitVisual()
+			return
+		}
+		e.Visual.Lines = cmd.action == "V"
+		v.Mode = VimVisual
+		if e.Visual.Lines {
+			v.Mode = VimVisualLine
+		}
+		return
+	case ":":
+		v.exitVisual()
+		v.Mode = VimCommandLine
+		v.CommandLine = ""
+		return
+	}
+	if operator == "" {
+		return
+	}
+	v.exitVisual()
+	v.operate(operator, cmd.register, lines, start, end, linewise)
+}
+
+// visualRange returns the visible offsets the visual selection covers,
+// start before end.
+func (e *Editor) visualRange() (int, int, bool) {
+	if !e.Visual.Active {
+		return 0, 0, false
+	}
+	lines := newLineIndex(e.RGA.GetText())
+	start, end := e.anchorOffset(e.Visual.Anchor), e.cursorOffset()
+	if start > end {
+		start, end = end, start
+	}
+	if e.Visual.Lines {
+		first, _ := lines.position(start)
+		last, _ := lines.position(end)
+		return lines.lineStart(first), lines.lineEnd(last), true
+	}
+	return start, min(end+1, len(lines.text)), true
+}
+
+func (v *Vim) exitVisual() {
+	v.editor.Visual = Visual{}
+	if v.visual() {
+		v.Mode = VimNormal
+	}
+}
+
+// move runs a motion without an operator. Vertical moves keep the goal
+// column.
+func (v *Vim) move(cmd vimCommand) {
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	line, _ := lines.position(e.cursorOffset())
+	switch cmd.motion {
+	case "j", "down":
+		e.moveCursor(e.moveVertical(min(cmd.times(), lines.lineCount()-1-line)), false)
+		return
+	case "k", "up":
+		e.moveCursor(e.moveVertical(-min(cmd.times(), line)), false)
+		return
+	}
+	target, _, _, ok := v.motionTarget(lines, e.cursorOffset(), cmd)
+	if ok {
+		e.moveCursor(func() { e.RGA.SetCursor(target) }, false)
+	}
+}
+
+// motionTarget returns where a motion takes the cursor, whether an operator
+// works on whole lines with it and whether it includes the target.
+func (v *Vim) motionTarget(lines *lineIndex, offset int, cmd vimCommand) (int, bool, bool, bool) {
+	n := cmd.times()
+	line, col := lines.position(offset)
+	text := lines.line(line)
+	switch cmd.motion {
+	case "h", "left", "backspace":
+		return lines.lineStart(line) + max(col-n, 0), false, false, col > 0
+	case "l", "right", " ":
+		return offset + min(n, len(text)-col), false, false, col < len(text)
+	case "j", "down":
+		return lines.offset(lines.clampLine(line+n), col), true, false, line+1 < lines.lineCount()
+	case "k", "up":
+		return lines.offset(lines.clampLine(line-n), col), true, false, line > 0
+	case "+", "enter":
+		return lines.firstNonBlank(lines.clampLine(line + n)), true, false, line+1 < lines.lineCount()
+	case "-":
+		return lines.firstNonBlank(lines.clampLine(line - n)), true, false, line > 0
+	case "w", "W":
+		target := offset
+		for i := 0; i < n; i++ {
+			target = lines.nextWordStart(target, cmd.motion == "W")
+		}
+		if cmd.operator != "" {
+			// An operator stops at the end of the line the last word is on.
+			if last, _ := lines.position(target); last > line && target <= lines.firstNonBlank(last) {
+				target = lines.lineEnd(last - 1)
+			}
+		}
+		return target, false, false, true
+	case "b", "B":
+		target := offset
+		for i := 0; i < n; i++ {
+			target = lines.prevWordStart(target, cmd.motion == "B")
+		}
+		return target, false, false, true
+	case "e", "E":
+		target := offset
+		for i := 0; i < n; i++ {
+			target = lines.nextWordEnd(target, cmd.motion == "E")
+		}
+		return target, false, true, len(lines.text) > 0
+	case "0", "home":
+		return lines.lineStart(line), false, false, true
+	case "^":
+		return lines.firstNonBlank(line), false, false, true
+	case "$", "end":
+		return lines.lineEnd(lines.clampLine(line + n - 1)), false, false, true
+	case "G", "gg":
+		target := 0
+		if cmd.motion == "G" {
+			target = lines.lineCount() - 1
+		}
+		if cmd.hasCount() {
+			target = lines.clampLine(n - 1)
+		}
+		return lines.firstNonBlank(target), true, false, true
+	case "%":
+		target, ok := lines.matchingBracket(offset)
+		return target, false, true, ok
+	case "f", "t":
+		target, found := []rune(cmd.arg)[0], col
+		for i := 0; i < n; i++ {
+			found++
+			for found < len(text) && text[found] != target {
+				found++
+			}
+			if found >= len(text) {
+				return offset, false, false, false
+			}
+		}
+		if cmd.motion == "t" {
+			found--
+		}
+		return lines.lineStart(line) + found, false, true, true
+	case "F", "T":
+		target, found := []rune(cmd.arg)[0], col
+		for i := 0; i < n; i++ {
+			found--
+			for found >= 0 && text[found] != target {
+				found--
+			}
+			if found < 0 {
+				return offset, false, false, false
+			}
+		}
+		if cmd.motion == "T" {
+			found++
+		}
+		return lines.lineStart(line) + found, false, false, true
+	}
+	return offset, false, false, false
+}
+
+// applyOperator runs an operator over the text a motion moves across.
+func (v *Vim) applyOperator(cmd vimCommand) {
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+
+	if cmd.motion == cmd.operator {
+		line, _ := lines.position(offset)
+		last := lines.clampLine(line + cmd.times() - 1)
+		v.operate(cmd.operator, cmd.register, lines, lines.lineStart(line), lines.lineStart(last), true)
+		return
+	}
+
+	big := cmd.motion == "W"
+	if cmd.operator == "c" && (cmd.motion == "w" || big) && offset < len(lines.text) && classOf(lines.text[offset]) != spaceClass {
+		// Like Vim, cw changes to the end of the word, even of a word of one
+		// character.
+		end := offset - 1
+		for i := 0; i < cmd.times(); i++ {
+			end = lines.nextWordEnd(end, big)
+		}
+		v.operate("c", cmd.register, lines, offset, end+1, false)
+		return
+	}
+
+	target, linewise, inclusive, ok := v.motionTarget(lines, offset, cmd)
+	if !ok {
+		return
+	}
+	start, end := offset, target
+	if start > end {
+		start, end = end, start
+	}
+	if inclusive && !linewise {
+		end = min(end+1, len(lines.text))
+	}
+	v.operate(cmd.operator, cmd.register, lines, start, end, linewise)
+}
+
+// operate applies an operator to the text from start to end, or to the
+// lines they are on.
+func (v *Vim) operate(operator string, name rune, lines *lineIndex, start int, end int, linewise bool) {
+	e := v.editor
+	e.ClearSelection()
+	if linewise {
+		first, _ := lines.position(start)
+		last, _ := lines.position(end)
+		v.operateLines(operator, name, lines, first, last)
+		return
+	}
+
+	text := string(lines.text[start:end])
+	switch operator {
+	case "y":
+		v.registers.store(name, text, false, true)
+		e.moveCursor(func() { e.RGA.SetCursor(start) }, false)
+	case "d", "c":
+		v.registers.store(name, text, false, false)
+		e.sendBatch(e.RGA.LocalDeleteRange(start, end))
+		e.RGA.SetCursor(start)
+		e.updateLocalCursor()
+		if operator == "c" {
+			v.startInsert("c", 1)
+		}
+	case ">", "<":
+		first, _ := lines.position(start)
+		last, _ := lines.position(end)
+		v.operateLines(operator, name, lines, first, last)
+	}
+}
+
+func (v *Vim) operateLines(operator string, name rune, lines *lineIndex, first int, last int) {
+	e := v.editor
+	text := string(lines.text[lines.lineStart(first):lines.lineEnd(last)]) + "\n"
+	line, col := lines.position(e.cursorOffset())
+
+	switch operator {
+	case "y":
+		v.registers.store(name, text, true, true)
+		if line > first {
+			e.moveCursor(func() { e.RGA.SetCursor(lines.offset(first, col)) }, false)
+		}
+	case "d":
+		v.registers.store(name, text, true, false)
+		start, end := lines.lineStart(first), lines.lineStart(last+1)
+		if last+1 >= lines.lineCount() {
+			// The last line has no break of its own, take the one before it.
+			end = len(lines.text)
+			if first > 0 {
+				start = lines.lineEnd(first - 1)
+			}
+		}
+		e.sendBatch(e.RGA.LocalDeleteRange(start, end))
+		lines = newLineIndex(e.RGA.GetText())
+		e.RGA.SetCursor(lines.firstNonBlank(lines.clampLine(first)))
+		e.updateLocalCursor()
+	case "c":
+		v.registers.store(name, text, true, false)
+		indent := leadingWhitespace(lines.line(first))
+		ops := e.RGA.LocalDeleteRange(lines.lineStart(first), lines.lineEnd(last))
+		ops = append(ops, e.insertAt(lines.lineStart(first), indent)...)
+		e.sendBatch(ops)
+		e.updateLocalCursor()
+		v.startInsert("c", 1)
+	case ">", "<":
+		v.selectLines(first, last)
+		if operator == ">" {
+			e.IndentLines()
+		} else {
+			e.OutdentLines()
+		}
+		e.ClearSelection()
+		lines = newLineIndex(e.RGA.GetText())
+		e.RGA.SetCursor(lines.firstNonBlank(first))
+		e.updateLocalCursor()
+	}
+}
+
+// selectLines selects the lines from first to last, for the line commands
+// of the editor.
+func (v *Vim) selectLines(first int, last int) {
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	e.ClearSelection()
+	e.RGA.SetCursor(lines.lineStart(first))
+	e.startSelection()
+	e.RGA.SetCursor(lines.lineEnd(lines.clampLine(last)))
+}
+
+// put pastes a register count times after or before the cursor, linewise
+// registers on lines of their own.
+func (v *Vim) put(reg register, before bool, count int) {
+	e := v.editor
+	if reg.text == "" {
+		return
+	}
+	e.ClearSelection()
+	lines := newLineIndex(e.RGA.GetText())
+	offset := e.cursorOffset()
+	line, col := lines.position(offset)
+	text := strings.Repeat(reg.text, count)
+
+	if reg.linewise {
+		at, target := lines.lineStart(line), line
+		if !before {
+			at, target = lines.lineEnd(line), line+1
+			text = "\n" + strings.TrimSuffix(text, "\n")
+		}
+		e.sendBatch(e.insertAt(at, text))
+		lines = newLineIndex(e.RGA.GetText())
+		e.RGA.SetCursor(lines.firstNonBlank(target))
+	} else {
+		at := offset
+		if !before && col < len(lines.line(line)) {
+			at++
+		}
+		e.sendBatch(e.insertAt(at, text))
+		e.RGA.SetCursor(at + len([]rune(text)) - 1)
+	}
+	e.updateLocalCursor()
+}
+
+// openLineAbove inserts an empty line above the cursor with the indentation
+// of the cursor line.
+func (v *Vim) openLineAbove() {
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	line, _ := lines.position(e.cursorOffset())
+	indent := leadingWhitespace(lines.line(line))
+	at := lines.lineStart(line)
+	e.ClearSelection()
+	e.sendBatch(e.insertAt(at, indent+"\n"))
+	e.RGA.SetCursor(at + len([]rune(indent)))
+	e.updateLocalCursor()
+}
+
+func (v *Vim) startInsert(action string, count int) {
+	v.Mode = VimInsert
+	v.insert = vimInsert{action: action, count: count}
+	v.inserted = nil
+}
+
+// exitInsert returns to normal mode. A count on the command that started
+// the insert types the text that many times.
+func (v *Vim) exitInsert() {
+	for i := 1; i < v.insert.count; i++ {
+		if v.insert.action == "o" || v.insert.action == "O" {
+			v.editor.NewLine()
+		}
+		v.typeKeys(v.inserted)
+	}
+	if v.recording && !v.replaying {
+		v.lastChange.inserted = append([]tea.KeyMsg{}, v.inserted...)
+	}
+	v.recording = false
+	v.Mode = VimNormal
+
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	if _, col := lines.position(e.cursorOffset()); col > 0 {
+		e.moveCursor(e.RGA.MoveCursorLeft, false)
+	}
+}
+
+func (v *Vim) typeKeys(keys []tea.KeyMsg) {
+	for _, msg := range keys {
+		v.handler.HandleKeyMsg(msg)
+	}
+}
+
+// repeat runs the last change again. A count replaces the one it had.
+func (v *Vim) repeat(count int) {
+	change := v.lastChange
+	cmd := change.command
+	if cmd == (vimCommand{}) {
+		return
+	}
+	if count > 0 {
+		cmd.count, cmd.motionCount = count, 0
+	}
+	v.replaying = true
+	defer func() { v.replaying = false }()
+	v.execute(cmd)
+	if v.Mode == VimInsert {
+		v.typeKeys(change.inserted)
+		v.inserted = change.inserted
+		v.exitInsert()
+	}
+}
+
+func (v *Vim) clampCursor() {
+	e := v.editor
+	lines := newLineIndex(e.RGA.GetText())
+	line, col := lines.position(e.cursorOffset())
+	if length := len(lines.line(line)); col > 0 && col >= length {
+		e.RGA.SetCursor(lines.offset(line, length-1))
+		e.syncLocalCursor()
+	}
+}
+
+func (v *Vim) commandLineKey(msg tea.KeyMsg) []string {
+	switch msg.Type {
+	case tea.KeyEsc:
+		v.Mode = VimNormal
+	case tea.KeyEnter:
+		v.Mode = VimNormal
+		return v.runCommandLine(v.CommandLine)
+	case tea.KeyBackspace:
+		runes := []rune(v.CommandLine)
+		if len(runes) == 0 {
+			v.Mode = VimNormal
+			break
+		}
+		v.CommandLine = string(runes[:len(runes)-1])
+	case tea.KeyRunes, tea.KeySpace:
+		v.CommandLine += string(msg.Runes)
+	}
+	return nil
+}
+
+// runCommandLine runs an ex command: a line number, or the commands for
+// files, buffers and windows like :w, :q, :e, :bn and :sp which the UI
+// carries out.
+func (v *Vim) runCommandLine(line string) []string {
+	line = strings.TrimSpace(line)
+	if number, err := strconv.Atoi(line); err == nil {
+		lines := newLineIndex(v.editor.RGA.GetText())
+		target := lines.clampLine(number - 1)
+		v.editor.moveCursor(func() { v.editor.RGA.SetCursor(lines.firstNonBlank(target)) }, false)
+		return nil
+	}
+	switch line {
+	case "":
+		return nil
+	case "w":
+		return []string{"save"}
+	case "w!":
+		return []string{"save!"}
+	case "q":
+		return []string{"quit"}
+	case "q!":
+		return []string{"quit!"}
+	case "wq", "x":
+		return []string{"save", "quit"}
+	case "wa":
+		return []string{"save-all"}
+	case "qa":
+		return []string{"quit-all"}
+	case "qa!":
+		return []string{"quit-all!"}
+	case "wqa", "xa":
+		return []string{"save-all", "quit-all"}
+	case "sp", "split":
+		return []string{"split-horizontal"}
+	case "vs", "vsplit":
+		return []string{"split-vertical"}
+	case "clo", "close":
+		return []string{"close-pane"}
+	case "on", "only":
+		return []string{"only-pane"}
+	case "bn", "bnext":
+		return []string{"next-buffer"}
+	case "bp", "bprevious":
+		return []string{"previous-buffer"}
+	case "bd", "bdelete":
+		return []string{"close-buffer"}
+	case "bd!", "bdelete!":
+		return []string{"close-buffer!"}
+	case "Ex", "Explore":
+		return []string{"toggle-files"}
+	case "ls", "buffers":
+		return []string{"list-buffers"}
+	}
+	if file, ok := strings.CutPrefix(line, "e "); ok {
+		return []string{"edit " + strings.TrimSpace(file)}
+	}
+	if file, ok := strings.CutPrefix(line, "saveas "); ok {
+		return []string{"save-as " + strings.TrimSpace(file)}
+	}
+	v.Message = "Not an editor command: " + line
+	return nil
+}
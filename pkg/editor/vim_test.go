@@ -0,0 +1,275 @@
+package editor
+
+import (
+	"edigo/pkg/crdt"
+	"edigo/pkg/editorconfig"
+	"edigo/pkg/keymap"
+	"edigo/pkg/network"
+	"edigo/pkg/theme"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestEditor is an editor outside any session holding text, with the
+// cursor where text has a "|".
+func newTestEditor(text string) *Editor {
+	cursor := strings.Index(text, "|")
+	text = strings.Replace(text, "|", "", 1)
+	rga := crdt.NewRGA("test")
+	for _, char := range text {
+		rga.LocalInsert(char)
+	}
+	e := &Editor{
+		RGA:           rga,
+		Network:       &network.Network{},
+		Theme:         theme.NewTheme(),
+		Viewport:      viewport.New(80, 24),
+		RemoteCursors: make(map[string]CursorInfo),
+		users:         make(map[string]CursorInfo),
+		documents:     make(map[string]*Document),
+		remoteFormats: make(map[string]editorconfig.Settings),
+		renamed:       make(map[string]string),
+		disk:          make(map[string]diskStamp),
+		Indent:        IndentSettings{TabWidth: 4, IndentSize: 4},
+		Document:      "test.txt",
+	}
+	e.RGA.SetCursor(len([]rune(text[:max(cursor, 0)])))
+	e.syncLocalCursor()
+	return e
+}
+
+// textWithCursor shows the text with a "|" before the cursor.
+func textWithCursor(e *Editor) string {
+	text := []rune(e.RGA.GetText())
+	offset := e.cursorOffset()
+	return string(text[:offset]) + "|" + string(text[offset:])
+}
+
+// typeVim sends keys the way the UI does: Vim first, then the input
+// handler. "<esc>" is the escape key, every other rune a key of its own.
+func typeVim(ih *InputHandler, keys string) {
+	for keys != "" {
+		msg := tea.KeyMsg{Type: tea.KeyRunes}
+		if rest, ok := strings.CutPrefix(keys, "<esc>"); ok {
+			msg, keys = tea.KeyMsg{Type: tea.KeyEsc}, rest
+		} else {
+			r := []rune(keys)[0]
+			msg.Runes = []rune{r}
+			if r == ' ' {
+				msg.Type = tea.KeySpace
+			}
+			keys = keys[len(string(r)):]
+		}
+		if _, handled := ih.Vim.HandleKey(msg); !handled {
+			ih.HandleKeyMsg(msg)
+		}
+	}
+}
+
+func split(keys string) []string {
+	return strings.Split(keys, "")
+}
+
+func TestParseVim(t *testing.T) {
+	tests := []struct {
+		keys   string
+		visual bool
+		want   vimCommand
+		state  vimParse
+	}{
+		{keys: "d", state: vimPending},
+		{keys: "dw", want: vimCommand{operator: "d", motion: "w"}},
+		{keys: "2d3w", want: vimCommand{count: 2, operator: "d", motionCount: 3, motion: "w"}},
+		{keys: "dd", want: vimCommand{operator: "d", motion: "d"}},
+		{keys: "d2", state: vimPending},
+		{keys: "dx", state: vimInvalid},
+		{keys: `"ayy`, want: vimCommand{register: 'a', operator: "y", motion: "y"}},
+		{keys: `"`, state: vimPending},
+		{keys: `"!`, state: vimInvalid},
+		{keys: "0", want: vimCommand{motion: "0"}},
+		{keys: "10j", want: vimCommand{count: 10, motion: "j"}},
+		{keys: "g", state: vimPending},
+		{keys: "gg", want: vimCommand{motion: "gg"}},
+		{keys: "5gg", want: vimCommand{count: 5, motion: "gg"}},
+		{keys: "gx", state: vimInvalid},
+		{keys: "f", state: vimPending},
+		{keys: "dt)", want: vimCommand{operator: "d", motion: "t", arg: ")"}},
+		{keys: "3F,", want: vimCommand{count: 3, motion: "F", arg: ","}},
+		{keys: "r", state: vimPending},
+		{keys: "rx", want: vimCommand{action: "r", arg: "x"}},
+		{keys: "3.", want: vimCommand{count: 3, action: "."}},
+		{keys: "z", state: vimInvalid},
+		{keys: "d", visual: true, want: vimCommand{operator: "d"}},
+		{keys: "2>", visual: true, want: vimCommand{count: 2, operator: ">"}},
+	}
+	for _, tt := range tests {
+		cmd, state := parseVim(split(tt.keys), tt.visual)
+		if state != tt.state {
+			t.Errorf("parseVim(%q) state = %d, want %d", tt.keys, state, tt.state)
+			continue
+		}
+		if state == vimComplete && cmd != tt.want {
+			t.Errorf("parseVim(%q) = %+v, want %+v", tt.keys, cmd, tt.want)
+		}
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		keys  string
+		at    int
+		count int
+		next  int
+	}{
+		{keys: "12x", count: 12, next: 2},
+		{keys: "0", count: 0, next: 0},
+		{keys: "10", count: 10, next: 2},
+		{keys: "d3w", at: 1, count: 3, next: 2},
+		{keys: "999999j", count: 99999, next: 6},
+	}
+	for _, tt := range tests {
+		count, next := parseCount(split(tt.keys), tt.at)
+		if count != tt.count || next != tt.next {
+			t.Errorf("parseCount(%q, %d) = %d, %d, want %d, %d", tt.keys, tt.at, count, next, tt.count, tt.next)
+		}
+	}
+}
+
+func TestVimEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		keys string
+		want string
+	}{
+		{name: "x", text: "|abc", keys: "x", want: "|bc"},
+		{name: "x at line end", text: "ab|c", keys: "x", want: "a|b"},
+		{name: "dw", text: "|foo bar", keys: "dw", want: "|bar"},
+		{name: "d2w", text: "|a b c", keys: "d2w", want: "|c"},
+		{name: "2dw", text: "|a b c", keys: "2dw", want: "|c"},
+		{name: "dw stops at the line end", text: "|foo\nbar", keys: "dw", want: "|\nbar"},
+		{name: "de", text: "|foo bar", keys: "de", want: "| bar"},
+		{name: "db", text: "foo |bar", keys: "db", want: "|bar"},
+		{name: "dd", text: "one\n|two\nthree", keys: "dd", want: "one\n|three"},
+		{name: "dd last line", text: "one\n|two", keys: "dd", want: "|one"},
+		{name: "2dd", text: "|a\nb\nc", keys: "2dd", want: "|c"},
+		{name: "dj", text: "|a\nb\nc", keys: "dj", want: "|c"},
+		{name: "dk", text: "a\n|b", keys: "dk", want: "|"},
+		{name: "dG", text: "a\n|b\nc", keys: "dG", want: "|a"},
+		{name: "dgg", text: "a\nb\n|c", keys: "dgg", want: "|"},
+		{name: "d$", text: "ab|cd", keys: "d$", want: "a|b"},
+		{name: "D", text: "ab|cd\nef", keys: "D", want: "a|b\nef"},
+		{name: "d0", text: "ab|cd", keys: "d0", want: "|cd"},
+		{name: "dt", text: "f(|abc)", keys: "dt)", want: "f(|)"},
+		{name: "df", text: "|a,b", keys: "df,", want: "|b"},
+		{name: "dF", text: "a,b|c", keys: "dF,", want: "a|c"},
+		{name: "d%", text: "x|(a)y", keys: "d%", want: "x|y"},
+		{name: "cw", text: "|foo bar", keys: "cwX<esc>", want: "|X bar"},
+		{name: "cw on one character", text: "|a bc", keys: "cwX<esc>", want: "|X bc"},
+		{name: "cc keeps the indent", text: "  |foo", keys: "ccx<esc>", want: "  |x"},
+		{name: "C", text: "a|bc", keys: "Cx<esc>", want: "a|x"},
+		{name: "s", text: "|abc", keys: "sx<esc>", want: "|xbc"},
+		{name: "r", text: "|abc", keys: "rZ", want: "|Zbc"},
+		{name: "3r", text: "|abcd", keys: "3rZ", want: "ZZ|Zd"},
+		{name: "r past the end", text: "|ab", keys: "3rZ", want: "|ab"},
+		{name: ">>", text: "|x", keys: ">>", want: "    |x"},
+		{name: "<<", text: "    |x", keys: "<<", want: "|x"},
+		{name: "yyp", text: "|one\ntwo", keys: "yyp", want: "one\n|one\ntwo"},
+		{name: "yyP", text: "one\n|two", keys: "yyP", want: "one\n|two\ntwo"},
+		{name: "ddp", text: "|a\nb", keys: "ddp", want: "b\n|a"},
+		{name: "yw then P", text: "|ab cd", keys: "ywP", want: "ab| ab cd"},
+		{name: "xp", text: "|ab", keys: "xp", want: "b|a"},
+		{name: "named register", text: "|one\ntwo", keys: `"ayyjdd"ap`, want: "one\n|one"},
+		{name: "black hole", text: "|a\nb", keys: `yyj"_ddp`, want: "a\n|a"},
+		{name: "i", text: "a|b", keys: "ix<esc>", want: "a|xb"},
+		{name: "a", text: "|ab", keys: "ax<esc>", want: "a|xb"},
+		{name: "A", text: "|ab", keys: "A!<esc>", want: "ab|!"},
+		{name: "I", text: "  a|b", keys: "Ix<esc>", want: "  |xab"},
+		{name: "o", text: "|a\nc", keys: "ob<esc>", want: "a\n|b\nc"},
+		{name: "O", text: "a\n|c", keys: "Ob<esc>", want: "a\n|b\nc"},
+		{name: "insert count", text: "|", keys: "3ia<esc>", want: "aa|a"},
+		{name: "J", text: "|a\nb", keys: "J", want: "a| b"},
+		{name: "v", text: "|abc", keys: "vld", want: "|c"},
+		{name: "V", text: "a\n|b\nc", keys: "Vd", want: "a\n|c"},
+		{name: "V over two lines", text: "|a\nb\nc", keys: "Vjd", want: "|c"},
+		{name: "v then y and P", text: "|ab", keys: "vlyP", want: "a|bab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEditor(tt.text)
+			ih := NewInputHandler(e, keymap.New(nil))
+			ih.SetVim(true)
+			typeVim(ih, tt.keys)
+			if got := textWithCursor(e); got != tt.want {
+				t.Errorf("%q on %q = %q, want %q", tt.keys, tt.text, got, tt.want)
+			}
+			if ih.Vim.Mode != VimNormal {
+				t.Errorf("mode = %d after %q, want normal", ih.Vim.Mode, tt.keys)
+			}
+		})
+	}
+}
+
+func TestVimRepeat(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		keys string
+		want string
+	}{
+		{name: "x", text: "|abcd", keys: "x..", want: "|d"},
+		{name: "dw", text: "|a b c", keys: "dw.", want: "|c"},
+		{name: "dd", text: "|a\nb\nc", keys: "dd.", want: "|c"},
+		{name: "count replaces the old one", text: "|abcdef", keys: "x3.", want: "|ef"},
+		{name: "count is kept", text: "|abcdefg", keys: "2x.", want: "|efg"},
+		{name: "insert", text: "|x", keys: "ihi<esc>.", want: "hh|iix"},
+		{name: "A", text: "|a\nb", keys: "A;<esc>j.", want: "a;\nb|;"},
+		{name: "cw", text: "|foo bar", keys: "cwX<esc>w.", want: "X |X"},
+		{name: "motions are not changes", text: "|abc", keys: "xl.", want: "|b"},
+		{name: "yank is not a change", text: "|abc", keys: "xyl.", want: "|c"},
+		{name: "nothing to repeat", text: "|abc", keys: ".", want: "|abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEditor(tt.text)
+			ih := NewInputHandler(e, keymap.New(nil))
+			ih.SetVim(true)
+			typeVim(ih, tt.keys)
+			if got := textWithCursor(e); got != tt.want {
+				t.Errorf("%q on %q = %q, want %q", tt.keys, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVimCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "w", want: []string{"save"}},
+		{line: "wq", want: []string{"save", "quit"}},
+		{line: "q!", want: []string{"quit!"}},
+		{line: "e main.go", want: []string{"edit main.go"}},
+		{line: "saveas new.go", want: []string{"save-as new.go"}},
+		{line: "sp", want: []string{"split-horizontal"}},
+		{line: "nonsense", want: nil},
+	}
+	for _, tt := range tests {
+		e := newTestEditor("|a")
+		v := NewVim(NewInputHandler(e, keymap.New(nil)))
+		if got := v.runCommandLine(tt.line); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("runCommandLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	e := newTestEditor("|a\nb\nc")
+	v := NewVim(NewInputHandler(e, keymap.New(nil)))
+	v.runCommandLine("3")
+	if got := textWithCursor(e); got != "a\nb\n|c" {
+		t.Errorf(":3 left the cursor at %q", got)
+	}
+}
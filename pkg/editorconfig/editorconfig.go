@@ -0,0 +1,267 @@
+package editorconfig
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Settings are the EditorConfig properties Edigo understands. Empty
+// strings, zero sizes and nil flags mean the property is not set.
+type Settings struct {
+	IndentStyle            string // "tab" or "space"
+	IndentSize             int
+	TabWidth               int
+	EndOfLine              string // "lf", "crlf" or "cr"
+	Charset                string // "utf-8", "utf-8-bom", "latin1", "utf-16be" or "utf-16le"
+	TrimTrailingWhitespace *bool
+	InsertFinalNewline     *bool
+}
+
+type section struct {
+	pattern    *regexp.Regexp
+	properties map[string]string
+}
+
+type file struct {
+	dir      string
+	root     bool
+	sections []section
+}
+
+// Resolve reads the .editorconfig files from the directory of path up to
+// the first one marked root and returns the settings that apply to path.
+// Files closer to path win, and so do later sections within a file.
+func Resolve(path string) (Settings, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	files := []file{}
+	dir := filepath.Dir(absPath)
+	for {
+		f, err := parseFile(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return Settings{}, err
+		}
+		if err == nil {
+			files = append(files, f)
+			if f.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	properties := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(files[i].dir, absPath)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range files[i].sections {
+			if !s.pattern.MatchString(rel) {
+				continue
+			}
+			for key, value := range s.properties {
+				properties[key] = value
+			}
+		}
+	}
+	return settingsFrom(properties), nil
+}
+
+func parseFile(dir string) (file, error) {
+	f, err := os.Open(filepath.Join(dir, ".editorconfig"))
+	if err != nil {
+		return file{}, err
+	}
+	defer f.Close()
+
+	result := file{dir: dir}
+	var current *section
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			pattern, err := compileSection(line[1 : len(line)-1])
+			if err != nil {
+				current = nil
+				continue
+			}
+			result.sections = append(result.sections, section{pattern: pattern, properties: map[string]string{}})
+			current = &result.sections[len(result.sections)-1]
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if current == nil {
+			if key == "root" {
+				result.root = value == "true"
+			}
+			continue
+		}
+		current.properties[key] = value
+	}
+	return result, scanner.Err()
+}
+
+// compileSection turns a section name into a regular expression matched
+// against paths relative to the .editorconfig. Names without a slash match
+// the file name in any directory.
+func compileSection(name string) (*regexp.Regexp, error) {
+	prefix := `(?:.*/)?`
+	if strings.Contains(name, "/") {
+		prefix = ""
+		name = strings.TrimPrefix(name, "/")
+	}
+	return regexp.Compile("^" + prefix + globToRegexp(name) + "$")
+}
+
+var numericRange = regexp.MustCompile(`^(-?\d+)\.\.(-?\d+)$`)
+
+func globToRegexp(glob string) string {
+	var out strings.Builder
+	for i := 0; i < len(glob); i++ {
+		ch := glob[i]
+		switch ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				out.WriteString(`.*`)
+				i++
+			} else {
+				out.WriteString(`[^/]*`)
+			}
+		case '?':
+			out.WriteString(`[^/]`)
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				out.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			out.WriteByte('[')
+			if strings.HasPrefix(class, "!") {
+				out.WriteByte('^')
+				class = class[1:]
+			}
+			out.WriteString(strings.ReplaceAll(class, `\`, `\\`))
+			out.WriteByte(']')
+			i += end + 1
+		case '{':
+			end := strings.IndexByte(glob[i+1:], '}')
+			if end < 0 {
+				out.WriteString(`\{`)
+				continue
+			}
+			inner := glob[i+1 : i+1+end]
+			i += end + 1
+			if m := numericRange.FindStringSubmatch(inner); m != nil {
+				out.WriteString(numberAlternatives(m[1], m[2]))
+				continue
+			}
+			if !strings.Contains(inner, ",") {
+				out.WriteString(regexp.QuoteMeta("{" + inner + "}"))
+				continue
+			}
+			alternatives := []string{}
+			for _, alternative := range strings.Split(inner, ",") {
+				alternatives = append(alternatives, globToRegexp(alternative))
+			}
+			out.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				out.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			out.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	return out.String()
+}
+
+// numberAlternatives matches the integers of a {n1..n2} range.
+func numberAlternatives(from string, to string) string {
+	low, _ := strconv.Atoi(from)
+	high, _ := strconv.Atoi(to)
+	if low > high {
+		low, high = high, low
+	}
+	if high-low > 1000 {
+		return `-?\d+`
+	}
+	numbers := []string{}
+	for n := low; n <= high; n++ {
+		numbers = append(numbers, strconv.Itoa(n))
+	}
+	return "(?:" + strings.Join(numbers, "|") + ")"
+}
+
+func settingsFrom(properties map[string]string) Settings {
+	value := func(key string) string {
+		v := properties[key]
+		if v == "unset" {
+			return ""
+		}
+		return v
+	}
+	flag := func(key string) *bool {
+		switch value(key) {
+		case "true":
+			b := true
+			return &b
+		case "false":
+			b := false
+			return &b
+		}
+		return nil
+	}
+
+	s := Settings{
+		TrimTrailingWhitespace: flag("trim_trailing_whitespace"),
+		InsertFinalNewline:     flag("insert_final_newline"),
+	}
+	switch v := value("indent_style"); v {
+	case "tab", "space":
+		s.IndentStyle = v
+	}
+	switch v := value("end_of_line"); v {
+	case "lf", "crlf", "cr":
+		s.EndOfLine = v
+	}
+	switch v := value("charset"); v {
+	case "utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le":
+		s.Charset = v
+	}
+	if n, err := strconv.Atoi(value("tab_width")); err == nil && n > 0 {
+		s.TabWidth = n
+	}
+	if n, err := strconv.Atoi(value("indent_size")); err == nil && n > 0 {
+		s.IndentSize = n
+		if s.TabWidth == 0 {
+			s.TabWidth = n
+		}
+	} else if value("indent_size") == "tab" {
+		s.IndentSize = s.TabWidth
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package editorconfig
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+)
+
+// Decode turns file content into text with \n line breaks. A byte order
+// mark wins over the charset setting. The returned settings hold the line
+// ending and charset the content was found in.
+func Decode(data []byte, charset string) (string, Settings) {
+	detected := Settings{Charset: charset}
+	var text string
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		detected.Charset = "utf-8-bom"
+		text = string(data[len(utf8BOM):])
+	case bytes.HasPrefix(data, utf16BEBOM):
+		detected.Charset = "utf-16be"
+		text = decodeUTF16(data[2:], binary.BigEndian)
+	case bytes.HasPrefix(data, utf16LEBOM):
+		detected.Charset = "utf-16le"
+		text = decodeUTF16(data[2:], binary.LittleEndian)
+	case charset == "utf-16be":
+		text = decodeUTF16(data, binary.BigEndian)
+	case charset == "utf-16le":
+		text = decodeUTF16(data, binary.LittleEndian)
+	case charset == "latin1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	default:
+		text = string(data)
+	}
+
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		switch {
+		case strings.HasPrefix(text[i:], "\r\n"):
+			detected.EndOfLine = "crlf"
+		case text[i] == '\r':
+			detected.EndOfLine = "cr"
+		default:
+			detected.EndOfLine = "lf"
+		}
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return text, detected
+}
+
+// Encode formats text with \n line breaks for writing to disk. It fails when
+// the charset cannot hold a character of the text.
+func (s Settings) Encode(text string) ([]byte, error) {
+	if s.TrimTrailingWhitespace != nil && *s.TrimTrailingWhitespace {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		text = strings.Join(lines, "\n")
+	}
+	if s.InsertFinalNewline != nil {
+		if *s.InsertFinalNewline {
+			if text != "" && !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+		} else {
+			text = strings.TrimRight(text, "\n")
+		}
+	}
+
+	switch s.EndOfLine {
+	case "crlf":
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	case "cr":
+		text = strings.ReplaceAll(text, "\n", "\r")
+	}
+
+	switch s.Charset {
+	case "utf-8-bom":
+		return append(append([]byte{}, utf8BOM...), text...), nil
+	case "utf-16be":
+		return encodeUTF16(text, utf16BEBOM, binary.BigEndian), nil
+	case "utf-16le":
+		return encodeUTF16(text, utf16LEBOM, binary.LittleEndian), nil
+	case "latin1":
+		out := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%q cannot be saved as latin1", r)
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	}
+	return []byte(text), nil
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func encodeUTF16(text string, bom []byte, order binary.AppendByteOrder) []byte {
+	units := utf16.Encode([]rune(text))
+	out := make([]byte, len(bom), len(bom)+2*len(units))
+	copy(out, bom)
+	for _, unit := range units {
+		out = order.AppendUint16(out, unit)
+	}
+	return out
+}
@@ -23,6 +23,10 @@ type SyntaxDefinition struct {
 	OperatorStyle lipgloss.Style
 	PunctuationStyle lipgloss.Style
 	DefaultStyle lipgloss.Style
+	UseTabs     bool     // indent with tabs instead of spaces
+	TabWidth    int      // cells a tab is drawn as
+	IndentSize  int      // spaces per indentation level when not using tabs
+	IndentAfter []string // line endings after which enter indents one level deeper
 }
 
 type Rule struct {
@@ -49,6 +53,10 @@ func NewPythonSyntaxDefinition() *SyntaxDefinition {
         NumberStyle :      lipgloss.NewStyle().Foreground(lipgloss.Color("#0000FF")),
         OperatorStyle :    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF00FF")),
         PunctuationStyle : lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF")),
+		UseTabs:     false,
+		TabWidth:    4,
+		IndentSize:  4,
+		IndentAfter: []string{":", "(", "[", "{"},
 	}
 }
 
@@ -70,6 +78,10 @@ func NewJavaScriptSyntaxDefinition() *SyntaxDefinition {
         NumberStyle :      lipgloss.NewStyle().Foreground(lipgloss.Color("#0000FF")),
         OperatorStyle :    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF00FF")),
         PunctuationStyle : lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF")),
+		UseTabs:     false,
+		TabWidth:    4,
+		IndentSize:  2,
+		IndentAfter: []string{"{", "(", "["},
 	}
 }
 
@@ -88,6 +100,10 @@ func NewHTMLSyntaxDefinition() *SyntaxDefinition {
         StringStyle :      lipgloss.NewStyle().Foreground(lipgloss.Color("#41B3A2")),
         OperatorStyle :    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF00FF")),
         PunctuationStyle : lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF")),
+		UseTabs:     false,
+		TabWidth:    4,
+		IndentSize:  2,
+		IndentAfter: []string{},
 	}
 }
 
@@ -102,5 +118,9 @@ func NewDefaultSyntaxDefinition() *SyntaxDefinition {
         NumberStyle :      lipgloss.NewStyle(),
         OperatorStyle :    lipgloss.NewStyle(),
         PunctuationStyle : lipgloss.NewStyle(),
+		UseTabs:     true,
+		TabWidth:    4,
+		IndentSize:  4,
+		IndentAfter: []string{"{", "(", "["},
 	}
 }
@@ -3,6 +3,7 @@ package highlighter
 import (
 	"regexp"
 	"strings"
+	"unicode/utf8"
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -72,7 +73,8 @@ func (sd *SyntaxDefinition) LineLexer(line string) []Token {
         }
         if matched{ continue }
 
-        remaining = remaining[1:]
+        _, size := utf8.DecodeRuneInString(remaining)
+        remaining = remaining[size:]
 	}
 	return tokens
 }
@@ -99,8 +101,9 @@ func (sd *SyntaxDefinition) EmiteColorText(input string, output string) string {
 
 
 		if longestMatch == "" {
-			result.WriteByte(remainingText[0])
-			remainingText = remainingText[1:]
+			_, size := utf8.DecodeRuneInString(remainingText)
+			result.WriteString(remainingText[:size])
+			remainingText = remainingText[size:]
             continue
         }
         result.WriteString(sd.colorizeText(longestMatch, color))
@@ -0,0 +1,124 @@
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher decides which paths of a project are ignored by its .gitignore
+// files. Paths are relative to the project root and use forward slashes.
+type Matcher struct {
+	root  string
+	rules []rule
+}
+
+type rule struct {
+	base     string // directory of the .gitignore the rule comes from
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// New reads the .gitignore of the root and .git/info/exclude. Nested
+// .gitignore files are added with AddDir while walking the tree.
+func New(root string) *Matcher {
+	m := &Matcher{root: root}
+	m.load(filepath.Join(root, ".git", "info", "exclude"), "")
+	m.AddDir("")
+	return m
+}
+
+// AddDir reads the .gitignore of a directory below the root.
+func (m *Matcher) AddDir(dir string) {
+	m.load(filepath.Join(m.root, filepath.FromSlash(dir), ".gitignore"), dir)
+}
+
+func (m *Matcher) load(file string, base string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := rule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// A slash anywhere but at the end ties the pattern to its directory.
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		m.rules = append(m.rules, r)
+	}
+}
+
+// Ignored reports whether a path is ignored. The last matching rule wins.
+// Callers skip ignored directories, so parents need not be checked.
+func (m *Matcher) Ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.matches(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r rule) matches(rel string) bool {
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		matched, _ := path.Match(r.pattern, path.Base(rel))
+		return matched
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches a pattern against a path segment by segment, where
+// a ** segment matches any number of segments.
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], segments[0])
+	return matched && matchSegments(pattern[1:], segments[1:])
+}
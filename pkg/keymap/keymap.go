@@ -0,0 +1,279 @@
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Command is a named action with the keys it is bound to by default.
+type Command struct {
+	Name        string
+	Title       string // shown in menus and the palette, the description when empty
+	Description string
+	Keys        []string
+}
+
+// Label is how menus and the command palette name the command.
+func (c Command) Label() string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return c.Description
+}
+
+// Profile rebinds commands for a style of editing. Like the keymap file it
+// maps command names to keys, and the keys it binds are taken away from
+// the commands that have them by default.
+type Profile map[string][]string
+
+// Conflict is a key that more than one command asks for, or that is bound
+// itself and starts a longer binding. The first command keeps it.
+type Conflict struct {
+	Key      string
+	Commands []string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s is bound to %s", c.Key, strings.Join(c.Commands, " and "))
+}
+
+// Keymap maps keys, as tea.KeyMsg.String() spells them, to commands. A
+// binding may be a sequence of keys separated by spaces, like
+// "ctrl+x ctrl+s". The space key itself is spelled "space".
+type Keymap struct {
+	Profile   string
+	Commands  []Command // in registration order, with the keys in effect
+	Conflicts []Conflict
+	bindings  map[string]string
+	prefixes  map[string]bool
+}
+
+// DefaultPath is the keymap file in the user config directory.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "edigo", "keymap.json")
+}
+
+// New binds every command to its default keys.
+func New(commands []Command) *Keymap {
+	return build(commands)
+}
+
+// Load binds the commands to the keys of the keymap file at path. The file
+// is a JSON object from command names to lists of keys, and a command it
+// lists loses its default keys. Its "profile" entry names a profile to
+// apply first; a non-empty profile argument, e.g. from the command line,
+// takes its place. Without a file the defaults apply. On an error the
+// defaults are returned along with it.
+func Load(commands []Command, profiles map[string]Profile, profile string, path string) (*Keymap, error) {
+	fallback := func(err error) (*Keymap, error) {
+		km, _ := Load(commands, profiles, profile, "")
+		return km, err
+	}
+
+	entries := map[string]json.RawMessage{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fallback(err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return fallback(fmt.Errorf("%s: %v", path, err))
+			}
+		}
+	}
+
+	if raw, ok := entries["profile"]; ok {
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return fallback(fmt.Errorf("%s: profile: %v", path, err))
+		}
+		if profile == "" {
+			profile = name
+		}
+		delete(entries, "profile")
+	}
+	if _, ok := profiles[profile]; !ok && profile != "" {
+		return build(commands), fmt.Errorf("unknown profile %q", profile)
+	}
+
+	known := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		known[command.Name] = true
+	}
+	overrides := Profile{}
+	for name, raw := range entries {
+		if !known[name] {
+			return fallback(fmt.Errorf("%s: unknown command %q", path, name))
+		}
+		keys := []string{}
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return fallback(fmt.Errorf("%s: %s: %v", path, name, err))
+		}
+		overrides[name] = keys
+	}
+
+	km := build(commands, profiles[profile], overrides)
+	km.Profile = profile
+	return km, nil
+}
+
+// build applies the layers to the defaults in order. The keys a layer binds
+// are taken away from the commands it does not list, along with the keys
+// that start or are started by one of them.
+func build(commands []Command, layers ...Profile) *Keymap {
+	bound := make([][]string, len(commands))
+	for i, command := range commands {
+		bound[i] = normalizeAll(command.Keys)
+	}
+	for _, layer := range layers {
+		claimed := []string{}
+		for _, keys := range layer {
+			claimed = append(claimed, normalizeAll(keys)...)
+		}
+		for i, command := range commands {
+			if keys, ok := layer[command.Name]; ok {
+				bound[i] = normalizeAll(keys)
+				continue
+			}
+			kept := []string{}
+			for _, k := range bound[i] {
+				if !overlapsAny(k, claimed) {
+					kept = append(kept, k)
+				}
+			}
+			bound[i] = kept
+		}
+	}
+
+	km := &Keymap{bindings: map[string]string{}, prefixes: map[string]bool{}}
+	owners := map[string][]string{}
+	for i, command := range commands {
+		for _, k := range bound[i] {
+			owners[k] = append(owners[k], command.Name)
+			if _, taken := km.bindings[k]; !taken {
+				km.bindings[k] = command.Name
+			}
+		}
+		command.Keys = bound[i]
+		km.Commands = append(km.Commands, command)
+	}
+
+	for k, names := range owners {
+		if len(names) > 1 {
+			km.Conflicts = append(km.Conflicts, Conflict{Key: k, Commands: names})
+		}
+		parts := strings.Split(k, " ")
+		for n := 1; n < len(parts); n++ {
+			prefix := strings.Join(parts[:n], " ")
+			if shadow, ok := km.bindings[prefix]; ok {
+				km.Conflicts = append(km.Conflicts, Conflict{Key: prefix, Commands: []string{shadow, km.bindings[k]}})
+			}
+			km.prefixes[prefix] = true
+		}
+	}
+	sort.Slice(km.Conflicts, func(i, j int) bool { return km.Conflicts[i].Key < km.Conflicts[j].Key })
+	return km
+}
+
+// Command returns the command bound to a single key, or "".
+func (km *Keymap) Command(key string) string {
+	return km.bindings[normalize(key)]
+}
+
+// Lookup returns the command bound to a sequence of keys. If there is none
+// it reports whether the keys start a longer binding.
+func (km *Keymap) Lookup(keys []string) (string, bool) {
+	sequence := strings.Join(normalizeAll(keys), " ")
+	if command, ok := km.bindings[sequence]; ok {
+		return command, false
+	}
+	return "", km.prefixes[sequence]
+}
+
+// Find returns the command registered under a name.
+func (km *Keymap) Find(name string) (Command, bool) {
+	for _, command := range km.Commands {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+// Keys returns the keys that run a command.
+func (km *Keymap) Keys(name string) []string {
+	keys := []string{}
+	for _, command := range km.Commands {
+		if command.Name != name {
+			continue
+		}
+		for _, k := range command.Keys {
+			if km.bindings[k] == name {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// Help is the first key of a command for hints, or "unbound".
+func (km *Keymap) Help(name string) string {
+	keys := km.Keys(name)
+	if len(keys) == 0 {
+		return "unbound"
+	}
+	return keys[0]
+}
+
+// overlapsAny reports whether a binding equals one of the others, or one is
+// a sequence starting with the other.
+func overlapsAny(k string, others []string) bool {
+	for _, other := range others {
+		if k == other || strings.HasPrefix(k, other+" ") || strings.HasPrefix(other, k+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeAll(keys []string) []string {
+	normalized := []string{}
+	for _, k := range keys {
+		if k == " " {
+			normalized = append(normalized, "space")
+			continue
+		}
+		parts := strings.Fields(k)
+		for i, part := range parts {
+			parts[i] = normalize(part)
+		}
+		if len(parts) > 0 {
+			normalized = append(normalized, strings.Join(parts, " "))
+		}
+	}
+	return normalized
+}
+
+// normalize spells a single key the way tea does. Characters keep their
+// case, names and modifiers are lower case.
+func normalize(k string) string {
+	if k == " " || strings.EqualFold(k, "space") {
+		return "space"
+	}
+	k = strings.TrimSpace(k)
+	rest := strings.TrimPrefix(k, "alt+")
+	if utf8.RuneCountInString(rest) == 1 {
+		return k[:len(k)-len(rest)] + rest
+	}
+	return strings.ToLower(k)
+}
@@ -0,0 +1,226 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testCommands = []Command{
+	{Name: "save", Keys: []string{"ctrl+s"}},
+	{Name: "find", Keys: []string{"ctrl+f"}},
+	{Name: "cut", Keys: []string{"ctrl+x"}},
+	{Name: "quit", Keys: []string{"ctrl+q"}},
+}
+
+var testProfiles = map[string]Profile{
+	"emacs": {
+		"save": {"ctrl+x ctrl+s"},
+		"find": {"ctrl+s"},
+	},
+}
+
+func writeKeymap(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keymap.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		profile string
+		want    map[string][]string
+		using   string
+	}{
+		{
+			name: "defaults",
+			file: `{}`,
+			want: map[string][]string{"save": {"ctrl+s"}, "find": {"ctrl+f"}, "cut": {"ctrl+x"}, "quit": {"ctrl+q"}},
+		},
+		{
+			name:  "profile from the file",
+			file:  `{"profile": "emacs"}`,
+			want:  map[string][]string{"save": {"ctrl+x ctrl+s"}, "find": {"ctrl+s"}, "cut": {}, "quit": {"ctrl+q"}},
+			using: "emacs",
+		},
+		{
+			name:    "profile argument",
+			file:    `{}`,
+			profile: "emacs",
+			want:    map[string][]string{"save": {"ctrl+x ctrl+s"}, "find": {"ctrl+s"}, "cut": {}, "quit": {"ctrl+q"}},
+			using:   "emacs",
+		},
+		{
+			name:  "file over profile",
+			file:  `{"profile": "emacs", "find": ["ctrl+r"], "quit": ["ctrl+x ctrl+c"]}`,
+			want:  map[string][]string{"save": {"ctrl+x ctrl+s"}, "find": {"ctrl+r"}, "cut": {}, "quit": {"ctrl+x ctrl+c"}},
+			using: "emacs",
+		},
+		{
+			name: "file takes a default key",
+			file: `{"quit": ["Ctrl+S"]}`,
+			want: map[string][]string{"save": {}, "find": {"ctrl+f"}, "cut": {"ctrl+x"}, "quit": {"ctrl+s"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km, err := Load(testCommands, testProfiles, tt.profile, writeKeymap(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if km.Profile != tt.using {
+				t.Errorf("profile = %q, want %q", km.Profile, tt.using)
+			}
+			for name, want := range tt.want {
+				if got := km.Keys(name); !reflect.DeepEqual(got, want) {
+					t.Errorf("Keys(%s) = %q, want %q", name, got, want)
+				}
+			}
+			if len(km.Conflicts) > 0 {
+				t.Errorf("unexpected conflicts %v", km.Conflicts)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		profile string
+	}{
+		{name: "unknown command", file: `{"frobnicate": ["ctrl+z"]}`},
+		{name: "keys are not a list", file: `{"save": "ctrl+s"}`},
+		{name: "broken json", file: `{"save": [`},
+		{name: "unknown profile", file: `{}`, profile: "nano"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km, err := Load(testCommands, testProfiles, tt.profile, writeKeymap(t, tt.file))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := km.Command("ctrl+s"); got != "save" {
+				t.Errorf("defaults not kept, ctrl+s runs %q", got)
+			}
+		})
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	km, err := Load(testCommands, testProfiles, "", filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := km.Command("ctrl+q"); got != "quit" {
+		t.Errorf("ctrl+q runs %q, want quit", got)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []Command
+		want     []Conflict
+	}{
+		{
+			name: "same key",
+			commands: []Command{
+				{Name: "save", Keys: []string{"ctrl+s"}},
+				{Name: "find", Keys: []string{"ctrl+s"}},
+			},
+			want: []Conflict{{Key: "ctrl+s", Commands: []string{"save", "find"}}},
+		},
+		{
+			name: "key starts a sequence",
+			commands: []Command{
+				{Name: "cut", Keys: []string{"ctrl+x"}},
+				{Name: "save", Keys: []string{"ctrl+x ctrl+s"}},
+			},
+			want: []Conflict{{Key: "ctrl+x", Commands: []string{"cut", "save"}}},
+		},
+		{
+			name: "spelled differently",
+			commands: []Command{
+				{Name: "save", Keys: []string{"CTRL+S"}},
+				{Name: "find", Keys: []string{"ctrl+s"}},
+			},
+			want: []Conflict{{Key: "ctrl+s", Commands: []string{"save", "find"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := New(tt.commands)
+			if !reflect.DeepEqual(km.Conflicts, tt.want) {
+				t.Errorf("conflicts = %v, want %v", km.Conflicts, tt.want)
+			}
+			if got := km.Command(tt.want[0].Key); got != tt.want[0].Commands[0] {
+				t.Errorf("%s runs %q, want the first command %q", tt.want[0].Key, got, tt.want[0].Commands[0])
+			}
+		})
+	}
+}
+
+func TestLayerStripsOverlappingKeys(t *testing.T) {
+	km := build(testCommands, Profile{"save": {"ctrl+x ctrl+s"}})
+	if got := km.Keys("cut"); len(got) != 0 {
+		t.Errorf("cut keeps %q, the prefix of save's sequence", got)
+	}
+	if len(km.Conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", km.Conflicts)
+	}
+
+	km = build(testCommands, Profile{"quit": {"ctrl+f ctrl+f"}})
+	if got := km.Keys("find"); len(got) != 0 {
+		t.Errorf("find keeps %q, which starts quit's sequence", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	km := build(testCommands, testProfiles["emacs"])
+	tests := []struct {
+		keys    []string
+		command string
+		prefix  bool
+	}{
+		{keys: []string{"ctrl+x"}, prefix: true},
+		{keys: []string{"ctrl+x", "ctrl+s"}, command: "save"},
+		{keys: []string{"CTRL+X", "Ctrl+S"}, command: "save"},
+		{keys: []string{"ctrl+s"}, command: "find"},
+		{keys: []string{"ctrl+x", "ctrl+f"}},
+		{keys: []string{"ctrl+y"}},
+	}
+	for _, tt := range tests {
+		command, prefix := km.Lookup(tt.keys)
+		if command != tt.command || prefix != tt.prefix {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.keys, command, prefix, tt.command, tt.prefix)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{" ", "space"},
+		{"Space", "space"},
+		{"CTRL+S", "ctrl+s"},
+		{"PgDown", "pgdown"},
+		{"G", "G"},
+		{"alt+O", "alt+O"},
+		{"ctrl+x  Ctrl+S", "ctrl+x ctrl+s"},
+	}
+	for _, tt := range tests {
+		got := normalizeAll([]string{tt.key})
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("normalizeAll(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
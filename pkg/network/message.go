@@ -0,0 +1,131 @@
+package network
+
+import (
+	"bytes"
+	"edigo/pkg/crdt"
+	"edigo/pkg/editorconfig"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+)
+
+type MessageType int
+
+const (
+	OperationMessage MessageType = iota
+	SnapshotMessage
+	HelloMessage
+	KickMessage
+	ChatMessage
+	ViewportMessage
+	FileListMessage
+	OpenDocumentMessage
+	SaveRequestMessage
+	SavedMessage
+	SaveDeclinedMessage
+	BatchMessage
+	GrepRequestMessage
+	GrepResultsMessage
+	GrepDoneMessage
+	FormatMessage
+	RenameMessage
+)
+
+// ViewRange is the first and last document line a user has on screen.
+type ViewRange struct {
+	Top    int
+	Bottom int
+}
+
+// SearchQuery is a project search a guest asks the host to run.
+type SearchQuery struct {
+	ID            int
+	Pattern       string
+	Regex         bool
+	CaseSensitive bool
+	WholeWord     bool
+}
+
+// SearchHit is one matching line of a project search. Line and Column
+// start at 0, Column counts runes.
+type SearchHit struct {
+	Path   string
+	Line   int
+	Column int
+	Text   string
+}
+
+// Message is the envelope for everything sent over a session connection.
+// Each message is framed by its gob encoded size, like the initial RGA.
+type Message struct {
+	Type      MessageType
+	Sender    string
+	Operation crdt.Operation
+	Batch     []crdt.Operation // operations of one edit that peers apply together
+	Snapshot  *crdt.RGA
+	Text      string
+	View      ViewRange
+	Document  string   // session path of the file the message refers to
+	Paths     []string // shared session paths
+	Query     SearchQuery
+	Hits      []SearchHit
+	Format    editorconfig.Settings // resolved .editorconfig of Document
+	Renamed   string                // new session path of Document, for RenameMessage
+	History   bool                  // chat message replayed to a guest that joined late
+}
+
+const maxMessageSize = 64 << 20
+
+func (network *Network) SendMessage(msg Message, conn net.Conn) {
+	if msg.Sender == "" {
+		msg.Sender = network.ID
+	}
+	sendMessage(msg, conn)
+}
+
+func sendMessage(msg Message, conn net.Conn) {
+	bin_buf := new(bytes.Buffer)
+	gobobj := gob.NewEncoder(bin_buf)
+	err := gobobj.Encode(msg)
+	if err != nil {
+		fmt.Printf("Fehler beim Kodieren der Nachricht: %v\n", err)
+		return
+	}
+
+	// Size and payload go out in a single write so concurrent senders
+	// cannot interleave their frames.
+	frame := new(bytes.Buffer)
+	binary.Write(frame, binary.BigEndian, int64(bin_buf.Len()))
+	frame.Write(bin_buf.Bytes())
+
+	_, err = conn.Write(frame.Bytes())
+	if err != nil {
+		fmt.Printf("Fehler beim Senden der Nachricht: %v\n", err)
+	}
+}
+
+func ReceiveMessage(conn net.Conn) (Message, error) {
+	var size int64
+	err := binary.Read(conn, binary.BigEndian, &size)
+	if err != nil {
+		return Message{}, err
+	}
+	if size <= 0 || size > maxMessageSize {
+		return Message{}, fmt.Errorf("ungültige Nachrichtengröße: %d", size)
+	}
+
+	tmp := make([]byte, size)
+	_, err = io.ReadFull(conn, tmp)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var msg Message
+	err = gob.NewDecoder(bytes.NewBuffer(tmp)).Decode(&msg)
+	if err != nil {
+		return Message{}, fmt.Errorf("Fehler beim Dekodieren der Nachricht: %v", err)
+	}
+	return msg, nil
+}
@@ -1,12 +1,8 @@
 package network
 
 import (
-	"bytes"
 	"edigo/pkg/crdt"
-	"encoding/binary"
-	"encoding/gob"
 	"fmt"
-	"io"
 	"math/rand"
 	"net"
 	"os"
@@ -28,13 +24,21 @@ type Network struct {
 	IsHost         bool
 	ID             string
 	Host           net.Conn           // isHost = false
-	Clients        []net.Conn         // isHost = true
+	clients        []net.Conn         // isHost = true, guarded by clientsMu
 	Sessions       map[string]Session // found connections
 	NewConnection  chan net.Conn
 	CurrentSession string // "" -> keine Session offen
 	UdpPort        int
 	HostFilePath   string // Store the host's file path
 	HostFileExt    string // Store the host's file extension
+	HostDocument   string // session path of the file every guest starts with
+	BannedIDs      map[string]bool
+	BannedIPs      map[string]bool
+	peers          map[net.Conn]string // client connection -> announced peer ID
+	clientsMu      sync.Mutex
+	// RelayM orders what the guests receive: snapshots for new guests and
+	// the edits passed on to them are sent under it.
+	RelayM sync.Mutex
 }
 
 type Session struct {
@@ -90,7 +94,8 @@ func NewNetwork() *Network {
 		os.Exit(1)
 	}
 
-	network := &Network{IsHost: false, ID: generateNetworkID(), Sessions: make(map[string]Session), UdpPort: udpPort, CurrentSession: "", NewConnection: make(chan net.Conn)}
+	network := &Network{IsHost: false, ID: generateNetworkID(), Sessions: make(map[string]Session), UdpPort: udpPort, CurrentSession: "", NewConnection: make(chan net.Conn),
+		BannedIDs: make(map[string]bool), BannedIPs: make(map[string]bool), peers: make(map[net.Conn]string)}
 	return network
 }
 
@@ -166,6 +171,8 @@ func (network *Network) BroadcastSession(rga *crdt.RGA) {
 
 	port := listener.Addr().(*net.TCPAddr).Port
 	sessionName := fmt.Sprintf("Session-%d", port)
+	network.IsHost = true
+	network.CurrentSession = sessionName
 
 	go func() {
 		for {
@@ -206,56 +213,53 @@ func (network *Network) BroadcastSession(rga *crdt.RGA) {
 				fmt.Printf("Fehler beim Akzeptieren der Verbindung: %v\n", err)
 				continue
 			}
-			network.Clients = append(network.Clients, conn)
-			network.NewConnection <- conn
+			if network.isBannedIP(conn) {
+				network.SendMessage(Message{Type: KickMessage, Sender: network.ID, Text: BannedReason}, conn)
+				conn.Close()
+				continue
+			}
 			network.IsHost = true
-			SendInitRGA(*rga, conn)
+			go network.admit(conn, rga)
 		}
 	}
 }
 
-func (network *Network) JoinSession(sessionName string) crdt.RGA {
+func (network *Network) JoinSession(sessionName string) (crdt.RGA, error) {
 	sessionMutex.Lock()
 	session, exists := network.Sessions[sessionName]
 	sessionMutex.Unlock()
 
 	if !exists {
-		fmt.Println("Sitzung nicht gefunden.")
-		return crdt.RGA{}
+		return crdt.RGA{}, fmt.Errorf("Sitzung nicht gefunden")
 	}
 
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", session.IP, session.Port))
+	conn, err := net.Dial("tcp", net.JoinHostPort(session.IP, strconv.Itoa(session.Port)))
 	if err != nil {
-		fmt.Printf("Fehler beim Verbinden mit der Sitzung: %v\n", err)
-		return crdt.RGA{}
+		return crdt.RGA{}, fmt.Errorf("Fehler beim Verbinden mit der Sitzung: %v", err)
 	}
 
-	// First read the size of the incoming data
-	var size int64
-	err = binary.Read(conn, binary.BigEndian, &size)
+	// Der Host prüft die ID, bevor er das Dokument schickt.
+	network.SendMessage(Message{Type: HelloMessage, Sender: network.ID}, conn)
+
+	msg, err := ReceiveMessage(conn)
 	if err != nil {
 		conn.Close()
-		return crdt.RGA{}
+		return crdt.RGA{}, fmt.Errorf("Fehler beim Lesen der Initialdaten: %v", err)
 	}
-
-	// Now read the exact amount of data
-	tmp := make([]byte, size)
-	_, err = io.ReadFull(conn, tmp)
-	if err != nil {
-		fmt.Printf("Fehler beim Lesen der Initialdaten: %v\n", err)
+	if msg.Type == KickMessage {
 		conn.Close()
-		return crdt.RGA{}
+		return crdt.RGA{}, fmt.Errorf("%s", msg.Text)
 	}
+	if msg.Type != SnapshotMessage || msg.Snapshot == nil {
+		conn.Close()
+		return crdt.RGA{}, fmt.Errorf("Unerwartete Nachricht beim Beitreten")
+	}
+	tmpstruct := msg.Snapshot
 
-	tmpbuff := bytes.NewBuffer(tmp)
-	tmpstruct := new(crdt.RGA)
-	gobobj := gob.NewDecoder(tmpbuff)
-
-	err = gobobj.Decode(tmpstruct)
-	if err != nil {
-		fmt.Printf("Fehler beim Dekodieren der RGA-Daten: %v\n", err)
+	// Verify the integrity of the received RGA
+	if !tmpstruct.VerifyIntegrity() {
 		conn.Close()
-		return crdt.RGA{}
+		return crdt.RGA{}, fmt.Errorf("Integritätsprüfung der Initialdaten fehlgeschlagen")
 	}
 
 	network.Host = conn
@@ -264,31 +268,50 @@ func (network *Network) JoinSession(sessionName string) crdt.RGA {
 	network.CurrentSession = session.Name
 	network.HostFilePath = session.FilePath
 	network.HostFileExt = session.FileExt
+	network.HostDocument = msg.Document
 
-	// Verify the integrity of the received RGA
-	if !tmpstruct.VerifyIntegrity() {
-		return crdt.RGA{}
-	}
-
-	return *tmpstruct
+	return *tmpstruct, nil
 }
 
 func (network *Network) SendOperation(op crdt.Operation, conn net.Conn) {
-	bin_buf := new(bytes.Buffer)
-	gobobj := gob.NewEncoder(bin_buf)
-	err := gobobj.Encode(op)
-	if err != nil {
-		fmt.Printf("Fehler beim Kodieren der Operation: %v\n", err)
-		return
+	network.SendMessage(Message{Type: OperationMessage, Sender: network.ID, Operation: op}, conn)
+}
+
+// Clients returns the connections of the guests. It is a copy, so it may
+// be used while guests come and go.
+func (network *Network) Clients() []net.Conn {
+	network.clientsMu.Lock()
+	defer network.clientsMu.Unlock()
+	return append([]net.Conn(nil), network.clients...)
+}
+
+// IsClient reports whether conn is still a guest of the session. A kicked
+// guest may keep sending until its connection closes.
+func (network *Network) IsClient(conn net.Conn) bool {
+	network.clientsMu.Lock()
+	defer network.clientsMu.Unlock()
+	for _, c := range network.clients {
+		if c == conn {
+			return true
+		}
 	}
-	_, err = conn.Write(bin_buf.Bytes())
-	if err != nil {
-		fmt.Printf("Fehler beim Senden der Operation: %v\n", err)
+	return false
+}
+
+func (network *Network) removeConn(conn net.Conn) bool {
+	network.clientsMu.Lock()
+	defer network.clientsMu.Unlock()
+	for i, c := range network.clients {
+		if c == conn {
+			network.clients = append(network.clients[:i], network.clients[i+1:]...)
+			return true
+		}
 	}
+	return false
 }
 
 func (network *Network) CloseAsHost() {
-	for _, conn := range network.Clients {
+	for _, conn := range network.Clients() {
 		conn.Close()
 	}
 	network.CurrentSession = ""
@@ -304,36 +327,17 @@ func (network *Network) HostClosedSession() {
 }
 
 func (network *Network) RemoveClient(conn net.Conn) {
-	for i, c := range network.Clients {
-		if c == conn {
-			network.Clients = append((network.Clients)[:i], (network.Clients)[i+1:]...)
-			conn.Close()
-			return
-		}
-	}
-}
-
-func SendInitRGA(rga crdt.RGA, conn net.Conn) {
-	bin_buf := new(bytes.Buffer)
-	gobobj := gob.NewEncoder(bin_buf)
-	err := gobobj.Encode(rga)
-	if err != nil {
-		fmt.Printf("Fehler beim Kodieren der initialen RGA: %v\n", err)
-		return
-	}
+	sessionMutex.Lock()
+	delete(network.peers, conn)
+	sessionMutex.Unlock()
 
-	// Send the size of the data first
-	size := int64(bin_buf.Len())
-	err = binary.Write(conn, binary.BigEndian, size)
-	if err != nil {
-		return
+	if network.removeConn(conn) {
+		conn.Close()
 	}
+}
 
-	// Then send the actual data
-	_, err = conn.Write(bin_buf.Bytes())
-	if err != nil {
-		fmt.Printf("Fehler beim Senden der initialen RGA: %v\n", err)
-	}
+func (network *Network) SendInitRGA(rga crdt.RGA, conn net.Conn) {
+	network.SendMessage(Message{Type: SnapshotMessage, Document: network.HostDocument, Snapshot: &rga}, conn)
 }
 
 func getBroadcastAddress() (string, error) {
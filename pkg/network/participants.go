@@ -0,0 +1,148 @@
+package network
+
+import (
+	"edigo/pkg/crdt"
+	"net"
+	"time"
+)
+
+type BanMode int
+
+const (
+	NoBan BanMode = iota
+	BanID
+	BanIP
+)
+
+// helloTimeout is how long a new client has to introduce itself.
+const helloTimeout = 5 * time.Second
+
+const (
+	KickedReason = "You were removed from the session by the host"
+	BannedReason = "You are banned from this session"
+)
+
+type Participant struct {
+	ID   string
+	Addr string
+	IP   string
+}
+
+func (network *Network) Participants() []Participant {
+	clients := network.Clients()
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	participants := []Participant{}
+	for _, conn := range clients {
+		participants = append(participants, Participant{
+			ID:   network.peers[conn],
+			Addr: conn.RemoteAddr().String(),
+			IP:   remoteIP(conn),
+		})
+	}
+	return participants
+}
+
+// admit waits for the hello message of a new client and lets it in unless
+// its ID is banned. Banned clients never get to see the document.
+func (network *Network) admit(conn net.Conn, rga *crdt.RGA) {
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	msg, err := ReceiveMessage(conn)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil || msg.Type != HelloMessage {
+		conn.Close()
+		return
+	}
+
+	sessionMutex.Lock()
+	banned := network.BannedIDs[msg.Sender]
+	if !banned {
+		network.peers[conn] = msg.Sender
+	}
+	sessionMutex.Unlock()
+	if banned {
+		network.SendMessage(Message{Type: KickMessage, Sender: network.ID, Text: BannedReason}, conn)
+		conn.Close()
+		return
+	}
+
+	// Edits are applied and relayed under RelayM, so each one is either in
+	// the snapshot or relayed to the client after it.
+	network.RelayM.Lock()
+	crdt.InsertM.Lock()
+	snapshot := rga.Copy()
+	crdt.InsertM.Unlock()
+	network.clientsMu.Lock()
+	network.clients = append(network.clients, conn)
+	network.clientsMu.Unlock()
+	network.SendInitRGA(*snapshot, conn)
+	network.RelayM.Unlock()
+	network.NewConnection <- conn
+}
+
+func (network *Network) PeerID(conn net.Conn) string {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	return network.peers[conn]
+}
+
+// Kick disconnects the participant with the given address and optionally
+// bans its peer ID or IP for the rest of the session.
+func (network *Network) Kick(addr string, ban BanMode) (Participant, bool) {
+	for _, conn := range network.Clients() {
+		if conn.RemoteAddr().String() != addr {
+			continue
+		}
+
+		participant := Participant{ID: network.PeerID(conn), Addr: addr, IP: remoteIP(conn)}
+		reason := KickedReason
+
+		sessionMutex.Lock()
+		switch ban {
+		case BanID:
+			if participant.ID != "" {
+				network.BannedIDs[participant.ID] = true
+			}
+			reason = BannedReason
+		case BanIP:
+			network.BannedIPs[participant.IP] = true
+			reason = BannedReason
+		}
+		sessionMutex.Unlock()
+
+		network.kickConn(conn, reason)
+		return participant, true
+	}
+	return Participant{}, false
+}
+
+// kickConn tells the client why it is being removed and stops relaying to it.
+// The connection is only half closed so the reason reaches the client before
+// its reader sees the end of the stream.
+func (network *Network) kickConn(conn net.Conn, reason string) {
+	network.SendMessage(Message{Type: KickMessage, Text: reason}, conn)
+
+	network.removeConn(conn)
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.CloseWrite()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	} else {
+		conn.Close()
+	}
+}
+
+func (network *Network) isBannedIP(conn net.Conn) bool {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	return network.BannedIPs[remoteIP(conn)]
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
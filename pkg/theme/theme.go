@@ -24,6 +24,13 @@ type Theme struct {
 	StatusBarStyle        lipgloss.Style
 	UsernameStyle         lipgloss.Style
 	ErrorStyle            lipgloss.Style
+	ChatPanelStyle        lipgloss.Style
+	ChatTimeStyle         lipgloss.Style
+	SidebarStyle          lipgloss.Style
+	SelectionStyle        lipgloss.Style
+	SearchMatchStyle      lipgloss.Style
+	CurrentMatchStyle     lipgloss.Style
+	PaneSeparatorStyle    lipgloss.Style
 	LineNumberPadding     int
 	UserThemes            []UserTheme
 }
@@ -93,6 +100,26 @@ func NewTheme() *Theme {
 		ErrorStyle: baseStyle.Copy().
 			Foreground(errorColor).
 			Bold(true),
+		ChatPanelStyle: baseStyle.Copy().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(secondaryColor).
+			BorderLeft(true).
+			PaddingLeft(1),
+		ChatTimeStyle: baseStyle.Copy().
+			Foreground(mutedTextColor),
+		SidebarStyle: baseStyle.Copy().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(secondaryColor).
+			BorderRight(true),
+		SelectionStyle: baseStyle.Copy().
+			Background(primaryColor),
+		SearchMatchStyle: baseStyle.Copy().
+			Background(secondaryColor),
+		CurrentMatchStyle: lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#000000")).
+			Background(accentColor),
+		PaneSeparatorStyle: lipgloss.NewStyle().
+			Foreground(secondaryColor),
 		LineNumberPadding: 2,
 		UserThemes:        userThemes,
 	}
@@ -147,3 +174,28 @@ func (t *Theme) RenderUsername(username string, themeIndex int) string {
 func (t *Theme) RenderError(content string) string {
 	return t.ErrorStyle.Render(content)
 }
+
+func (t *Theme) RenderChatPanel(content string, width int, height int) string {
+	return t.ChatPanelStyle.Copy().
+		Width(width - 1).
+		Height(height).
+		MaxHeight(height).
+		Render(content)
+}
+
+func (t *Theme) RenderSidebar(content string, width int, height int) string {
+	return t.SidebarStyle.Copy().
+		Width(width - 1).
+		Height(height).
+		MaxHeight(height).
+		Render(content)
+}
+
+// RenderPaneSeparator is the line between panes side by side.
+func (t *Theme) RenderPaneSeparator(height int) string {
+	return t.PaneSeparatorStyle.Render(strings.TrimSuffix(strings.Repeat("│\n", height), "\n"))
+}
+
+func (t *Theme) RenderChatTime(content string) string {
+	return t.ChatTimeStyle.Render(content)
+}
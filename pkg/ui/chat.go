@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"edigo/pkg/editor"
+	"edigo/pkg/theme"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const chatPanelWidth = 36
+
+type ChatPanel struct {
+	Input  textinput.Model
+	Theme  *theme.Theme
+	Width  int
+	Height int
+}
+
+func NewChatPanel(theme *theme.Theme) ChatPanel {
+	input := textinput.New()
+	input.Placeholder = "Message"
+	input.Prompt = "> "
+	input.CharLimit = 500
+
+	return ChatPanel{
+		Input: input,
+		Theme: theme,
+		Width: chatPanelWidth,
+	}
+}
+
+func (c ChatPanel) Update(msg tea.Msg) (ChatPanel, tea.Cmd) {
+	var cmd tea.Cmd
+	c.Input, cmd = c.Input.Update(msg)
+	return c, cmd
+}
+
+func (c ChatPanel) View(history []editor.ChatEntry) string {
+	innerWidth := c.Width - 2
+	c.Input.Width = innerWidth - len(c.Input.Prompt) - 1
+
+	var lines []string
+	for _, entry := range history {
+		name := c.Theme.RenderUsername(entry.Username, entry.ThemeIndex)
+		lines = append(lines, name+" "+c.Theme.RenderChatTime(entry.Time.Format("15:04")))
+		lines = append(lines, strings.Split(lipgloss.NewStyle().Width(innerWidth).Render(entry.Text), "\n")...)
+	}
+
+	// Keep the newest messages visible above the input line.
+	visible := c.Height - 3 // title, its border and the input line
+	if visible < 0 {
+		visible = 0
+	}
+	if len(lines) > visible {
+		lines = lines[len(lines)-visible:]
+	}
+	for len(lines) < visible {
+		lines = append([]string{""}, lines...)
+	}
+
+	body := strings.Join(lines, "\n") + "\n" + c.Input.View()
+	return c.Theme.RenderChatPanel(c.Theme.RenderHeader("Chat")+"\n"+body, c.Width, c.Height)
+}
+
+func (c ChatPanel) Join(content string, history []editor.ChatEntry) string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, content, c.View(history))
+}
+
+func textinputBlink(focused bool) tea.Cmd {
+	if !focused {
+		return nil
+	}
+	return textinput.Blink
+}
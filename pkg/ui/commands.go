@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"edigo/pkg/editor"
+	"edigo/pkg/keymap"
+)
+
+// uiCommands are the commands handled above the text area. The ones with
+// a title also show up in the main menu.
+var uiCommands = []keymap.Command{
+	{Name: "open-menu", Description: "Open the menu", Keys: []string{"esc"}},
+	{Name: "command-palette", Title: "Command Palette", Description: "Find and run any command", Keys: []string{"ctrl+p"}},
+	{Name: "help", Title: "Key Bindings", Description: "List the keys and the commands they run", Keys: []string{"f1"}},
+	{Name: "create-session", Title: "Create Session", Description: "Start a new editing session"},
+	{Name: "join-session", Title: "Join Session", Description: "Join an existing editing session"},
+	{Name: "participants", Title: "Participants", Description: "Manage the users in your hosted session"},
+	{Name: "follow-user", Title: "Follow User", Description: "Keep your view on another collaborator's cursor"},
+	{Name: "open-shared-file", Title: "Open Shared File", Description: "Switch to another file of the session"},
+	{Name: "share-files", Title: "Share Files", Description: "Choose which project files guests may open"},
+	{Name: "open-file", Title: "Open File", Description: "Open a file in a new tab", Keys: []string{"ctrl+o"}},
+	{Name: "toggle-files", Title: "Files", Description: "Show or hide the file browser", Keys: []string{"ctrl+b"}},
+	{Name: "list-buffers", Title: "Open Files", Description: "Switch between the files open as tabs"},
+	{Name: "next-buffer", Description: "Go to the next tab", Keys: []string{"ctrl+pgdown"}},
+	{Name: "previous-buffer", Description: "Go to the previous tab", Keys: []string{"ctrl+pgup"}},
+	{Name: "close-buffer", Title: "Close File", Description: "Close the tab of the current file"},
+	{Name: "toggle-share", Title: "Share This File", Description: "Share the current file with guests, or stop sharing it"},
+	{Name: "split-horizontal", Title: "Split Horizontally", Description: "Show the file in a second pane below", Keys: []string{"alt+s"}},
+	{Name: "split-vertical", Title: "Split Vertically", Description: "Show the file in a second pane to the right", Keys: []string{"alt+\\"}},
+	{Name: "focus-next-pane", Description: "Move to the next pane", Keys: []string{"alt+o"}},
+	{Name: "focus-previous-pane", Description: "Move to the previous pane", Keys: []string{"alt+O"}},
+	{Name: "close-pane", Title: "Close Pane", Description: "Close the current pane", Keys: []string{"alt+q"}},
+	{Name: "only-pane", Title: "Close Other Panes", Description: "Keep only the current pane"},
+	{Name: "grow-pane", Description: "Make the current pane larger", Keys: []string{"alt+="}},
+	{Name: "shrink-pane", Description: "Make the current pane smaller", Keys: []string{"alt+-"}},
+	{Name: "save", Title: "Save", Description: "Save the current file", Keys: []string{"ctrl+s"}},
+	{Name: "save-as", Title: "Save As", Description: "Save the current file under a new name"},
+	{Name: "save-all", Title: "Save All", Description: "Save every file with unsaved changes"},
+	{Name: "save-copy", Title: "Save Local Copy As", Description: "Write the current file to a path of your choice"},
+	{Name: "ask-host-save", Title: "Ask Host to Save", Description: "Request that the host saves the shared file"},
+	{Name: "toggle-chat", Title: "Chat", Description: "Open or close the chat", Keys: []string{"ctrl+t"}},
+	{Name: "copy", Description: "Copy the selection", Keys: []string{"ctrl+c"}},
+	{Name: "cut", Description: "Cut the selection", Keys: []string{"ctrl+x"}},
+	{Name: "paste", Description: "Paste from the clipboard", Keys: []string{"ctrl+v"}},
+	{Name: "find", Description: "Find in the file", Keys: []string{"ctrl+f"}},
+	{Name: "find-next", Description: "Go to the next match", Keys: []string{"f3"}},
+	{Name: "find-previous", Description: "Go to the previous match", Keys: []string{"shift+f3"}},
+	{Name: "replace", Description: "Find and replace in the file", Keys: []string{"ctrl+r"}},
+	{Name: "search-project", Title: "Search in Project", Description: "Find a pattern in every file of the project", Keys: []string{"alt+f"}},
+	{Name: "go-to-line", Description: "Go to a line and column", Keys: []string{"ctrl+g"}},
+	{Name: "toggle-vim", Title: "Vim Mode", Description: "Turn modal editing on or off"},
+	{Name: "quit", Title: "Quit", Description: "Exit the editor"},
+}
+
+// mainMenu is the order of the commands in the main menu.
+var mainMenu = []string{
+	"create-session", "join-session", "participants", "follow-user", "open-shared-file", "share-files",
+	"toggle-files", "open-file", "list-buffers", "close-buffer", "toggle-share",
+	"split-horizontal", "split-vertical", "close-pane", "only-pane",
+	"search-project", "save", "save-as", "save-all", "save-copy", "ask-host-save", "command-palette", "help", "toggle-vim",
+}
+
+// profiles are the built-in styles of key bindings. The vim profile keeps
+// the default keys and starts in Vim mode.
+var profiles = map[string]keymap.Profile{
+	"default": {},
+	"vim":     {},
+	"emacs": {
+		"move-line-start":     {"ctrl+a", "home"},
+		"move-line-end":       {"ctrl+e", "end"},
+		"move-left":           {"ctrl+b", "left"},
+		"move-right":          {"ctrl+f", "right"},
+		"move-up":             {"ctrl+p", "up"},
+		"move-down":           {"ctrl+n", "down"},
+		"move-word-left":      {"alt+b", "ctrl+left"},
+		"move-word-right":     {"alt+f", "ctrl+right"},
+		"move-document-start": {"alt+<", "ctrl+home"},
+		"move-document-end":   {"alt+>", "ctrl+end"},
+		"page-up":             {"alt+v", "pgup"},
+		"page-down":           {"ctrl+v", "pgdown"},
+		"delete-forward":      {"ctrl+d", "delete"},
+		"set-mark":            {"ctrl+@"},
+		"cancel":              {"ctrl+g"},
+		"kill-line":           {"ctrl+k"},
+		"kill-region":         {"ctrl+w"},
+		"copy-region":         {"alt+w"},
+		"kill-word":           {"alt+d"},
+		"backward-kill-word":  {"alt+backspace"},
+		"yank":                {"ctrl+y"},
+		"yank-pop":            {"alt+y"},
+		"find":                {"ctrl+s"},
+		"find-previous":       {"ctrl+r"},
+		"replace":             {"alt+%"},
+		"go-to-line":          {"alt+g g", "alt+g alt+g"},
+		"select-all":          {"ctrl+x h"},
+		"save":                {"ctrl+x ctrl+s"},
+		"save-as":             {"ctrl+x ctrl+w"},
+		"save-all":            {"ctrl+x s"},
+		"open-file":           {"ctrl+x ctrl+f"},
+		"toggle-files":        {"ctrl+x d"},
+		"list-buffers":        {"ctrl+x b"},
+		"next-buffer":         {"ctrl+x right", "ctrl+pgdown"},
+		"previous-buffer":     {"ctrl+x left", "ctrl+pgup"},
+		"close-buffer":        {"ctrl+x k"},
+		"split-horizontal":    {"ctrl+x 2"},
+		"split-vertical":      {"ctrl+x 3"},
+		"focus-next-pane":     {"ctrl+x o"},
+		"close-pane":          {"ctrl+x 0"},
+		"only-pane":           {"ctrl+x 1"},
+		"grow-pane":           {"ctrl+x ^", "alt+="},
+		"quit":                {"ctrl+x ctrl+c"},
+		"command-palette":     {"alt+x"},
+		"copy":                {},
+		"cut":                 {},
+		"paste":               {},
+	},
+}
+
+// commands is the registry of every command a key can be bound to.
+func commands() []keymap.Command {
+	return append(append([]keymap.Command{}, uiCommands...), editor.Commands()...)
+}
+
+// loadKeymap reads the user's keymap on top of a profile, which when empty
+// comes from the keymap file. Problems with it are reported in the footer
+// and leave the defaults in place.
+func loadKeymap(profile string) (*keymap.Keymap, string) {
+	keys, err := keymap.Load(commands(), profiles, profile, keymap.DefaultPath())
+	if err != nil {
+		return keys, "Keymap: " + err.Error()
+	}
+	if len(keys.Conflicts) > 0 {
+		return keys, "Keymap: " + keys.Conflicts[0].String() + ", see " + keys.Help("help")
+	}
+	return keys, ""
+}
@@ -0,0 +1,340 @@
+package ui
+
+import (
+	"edigo/pkg/theme"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	fileBrowserWidth = 32
+	wheelRows        = 3
+)
+
+// fileEntry is a row of the file tree. Rows of the session's shared files
+// hold a session path instead of a file on disk.
+type fileEntry struct {
+	path   string
+	name   string
+	dir    bool
+	depth  int
+	shared bool
+}
+
+// FileBrowser is the sidebar with the tree of the working directory and
+// the files shared in the session.
+type FileBrowser struct {
+	Root     string
+	Theme    *theme.Theme
+	Width    int
+	Height   int
+	Focused  bool
+	expanded map[string]bool
+	entries  []fileEntry
+	selected int
+}
+
+func NewFileBrowser(theme *theme.Theme) FileBrowser {
+	root, err := os.Getwd()
+	if err != nil {
+		root = "."
+	}
+	return FileBrowser{Root: root, Theme: theme, Width: fileBrowserWidth, expanded: map[string]bool{}}
+}
+
+// Refresh reads the expanded directories again and lists the shared files
+// below them.
+func (b *FileBrowser) Refresh(shared []string) {
+	b.entries = b.readDir(b.Root, 0)
+	if len(shared) > 0 {
+		b.entries = append(b.entries, fileEntry{name: "Shared in session", dir: true})
+		for _, path := range shared {
+			b.entries = append(b.entries, fileEntry{path: path, name: path, depth: 1, shared: true})
+		}
+	}
+	b.selected = min(b.selected, max(len(b.entries)-1, 0))
+}
+
+// readDir lists a directory, directories first, and the contents of the
+// expanded ones. Hidden files are left out.
+func (b *FileBrowser) readDir(dir string, depth int) []fileEntry {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].IsDir() && !files[j].IsDir() })
+
+	entries := []fileEntry{}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		entry := fileEntry{path: filepath.Join(dir, file.Name()), name: file.Name(), dir: file.IsDir(), depth: depth}
+		entries = append(entries, entry)
+		if entry.dir && b.expanded[entry.path] {
+			entries = append(entries, b.readDir(entry.path, depth+1)...)
+		}
+	}
+	return entries
+}
+
+func (b *FileBrowser) Selected() (fileEntry, bool) {
+	if b.selected >= len(b.entries) {
+		return fileEntry{}, false
+	}
+	return b.entries[b.selected], true
+}
+
+func (b *FileBrowser) Move(delta int) {
+	b.selected = min(max(b.selected+delta, 0), max(len(b.entries)-1, 0))
+}
+
+// Expand opens or closes the selected directory.
+func (b *FileBrowser) Expand(open bool) {
+	entry, ok := b.Selected()
+	if !ok || !entry.dir || entry.path == "" {
+		return
+	}
+	b.expanded[entry.path] = open
+}
+
+// Parent selects the directory the selected row is in.
+func (b *FileBrowser) Parent() {
+	entry, ok := b.Selected()
+	if !ok {
+		return
+	}
+	for i := b.selected - 1; i >= 0; i-- {
+		if b.entries[i].dir && b.entries[i].depth < entry.depth {
+			b.selected = i
+			return
+		}
+	}
+}
+
+// SelectRow selects the row shown on a line of the sidebar.
+func (b *FileBrowser) SelectRow(line int) {
+	row := b.first() + line - 2 // title and its border
+	if row >= 0 && row < len(b.entries) {
+		b.selected = row
+	}
+}
+
+// Directory is where new files go: the selected directory, or the one of
+// the selected file.
+func (b *FileBrowser) Directory() string {
+	entry, ok := b.Selected()
+	switch {
+	case !ok || entry.shared || entry.path == "":
+		return b.Root
+	case entry.dir:
+		return entry.path
+	}
+	return filepath.Dir(entry.path)
+}
+
+// Resolve turns a path typed relative to the root of the tree into a file
+// path.
+func (b *FileBrowser) Resolve(value string) string {
+	if filepath.IsAbs(value) {
+		return filepath.Clean(value)
+	}
+	return filepath.Join(b.Root, filepath.FromSlash(value))
+}
+
+// Reveal expands the directories above a path.
+func (b *FileBrowser) Reveal(path string) {
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, b.Root) && dir != b.Root; dir = filepath.Dir(dir) {
+		b.expanded[dir] = true
+	}
+}
+
+// Relative shows a path relative to the root of the tree.
+func (b *FileBrowser) Relative(path string) string {
+	if rel, err := filepath.Rel(b.Root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func (b *FileBrowser) rows() int {
+	return max(b.Height-2, 1)
+}
+
+func (b *FileBrowser) first() int {
+	return max(b.selected-b.rows()+1, 0)
+}
+
+// View draws the tree. Files open in a tab are bold, unsaved ones get a
+// star and shared ones an arrow.
+func (b FileBrowser) View(status func(file string) (bool, bool, bool)) string {
+	width := b.Width - 1
+	lines := []string{}
+	for i := b.first(); i < len(b.entries) && len(lines) < b.rows(); i++ {
+		entry := b.entries[i]
+		icon := "  "
+		if entry.dir {
+			icon = "▸ "
+			if b.expanded[entry.path] || entry.path == "" {
+				icon = "▾ "
+			}
+		}
+		line := strings.Repeat("  ", entry.depth) + icon + entry.name
+		style := lipgloss.NewStyle()
+		if i == b.selected && b.Focused {
+			style = b.Theme.SelectionStyle.Copy()
+		}
+		if !entry.dir && !entry.shared {
+			open, modified, shared := status(entry.path)
+			if modified {
+				line += " *"
+			}
+			if shared {
+				line += " ⇄"
+			}
+			style = style.Bold(open)
+		}
+		lines = append(lines, style.MaxWidth(width).Render(line))
+	}
+	title := b.Theme.RenderHeader(filepath.Base(b.Root))
+	return b.Theme.RenderSidebar(title+"\n"+strings.Join(lines, "\n"), b.Width, b.Height)
+}
+
+func (b FileBrowser) Join(content string, status func(file string) (bool, bool, bool)) string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, b.View(status), content)
+}
+
+func (m *UIModel) toggleFiles() {
+	if m.ShowFiles && m.Files.Focused {
+		m.ShowFiles = false
+		m.Files.Focused = false
+	} else {
+		m.ShowFiles = true
+		m.Files.Focused = true
+		m.refreshFiles()
+	}
+	m.layout()
+}
+
+func (m *UIModel) refreshFiles() {
+	m.Files.Refresh(m.Editor.SharedFiles())
+}
+
+func (m *UIModel) updateFiles(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.Keymap.Command(msg.String()) == "toggle-files" {
+		m.toggleFiles()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	entry, selected := m.Files.Selected()
+	switch msg.String() {
+	case "esc", "tab":
+		// Hand the keyboard back to the editor but keep the sidebar open.
+		m.Files.Focused = false
+	case "up", "k":
+		m.Files.Move(-1)
+	case "down", "j":
+		m.Files.Move(1)
+	case "pgup":
+		m.Files.Move(-m.Files.rows())
+	case "pgdown":
+		m.Files.Move(m.Files.rows())
+	case "enter", "right", "l":
+		if selected {
+			m.openFileEntry(entry)
+		}
+	case "left", "h":
+		if selected && entry.dir && m.Files.expanded[entry.path] {
+			m.Files.Expand(false)
+		} else {
+			m.Files.Parent()
+		}
+		m.refreshFiles()
+	case "n":
+		dir := ""
+		if m.Files.Directory() != m.Files.Root {
+			dir = m.Files.Relative(m.Files.Directory()) + "/"
+		}
+		cmd = m.openPrompt(NewPrompt(NewFilePrompt, "New file (end with / for a directory):", dir, m.Theme))
+	case "r":
+		if selected && !entry.shared && entry.path != "" {
+			cmd = m.openPrompt(NewPrompt(RenameFilePrompt, "Rename to:", m.Files.Relative(entry.path), m.Theme))
+			m.Prompt.Data = entry.path
+		}
+	case "d", "delete":
+		if selected && !entry.shared && entry.path != "" {
+			title := "Delete " + m.Files.Relative(entry.path) + "?"
+			cmd = m.openPrompt(NewConfirmPrompt(DeleteFilePrompt, title, entry.path, m.Theme))
+		}
+	case "R":
+		m.refreshFiles()
+	}
+	m.Viewport.SetContent(m.Editor.RenderContent())
+	return m, cmd
+}
+
+// openFileEntry opens a file in a tab, or expands a directory.
+func (m *UIModel) openFileEntry(entry fileEntry) {
+	switch {
+	case entry.dir:
+		m.Files.Expand(!m.Files.expanded[entry.path])
+		m.refreshFiles()
+		return
+	case entry.shared:
+		m.Editor.OpenDocument(entry.path)
+	default:
+		m.Editor.OpenFile(entry.path)
+	}
+	m.Files.Focused = false
+}
+
+func (m *UIModel) fileBrowserMouse(msg tea.MouseMsg) {
+	if msg.Action != tea.MouseActionPress {
+		return
+	}
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.Files.Move(-wheelRows)
+	case tea.MouseButtonWheelDown:
+		m.Files.Move(wheelRows)
+	case tea.MouseButtonLeft:
+		m.Files.Focused = true
+		m.Files.SelectRow(msg.Y)
+	}
+}
+
+func (m *UIModel) handleFilePrompt(msg PromptMsg) {
+	var err error
+	switch msg.Kind {
+	case NewFilePrompt:
+		if strings.TrimSpace(msg.Value) == "" {
+			return
+		}
+		path := m.Files.Resolve(msg.Value)
+		if strings.HasSuffix(msg.Value, "/") {
+			err = m.Editor.CreateDirectory(path)
+		} else {
+			err = m.Editor.CreateFile(path)
+		}
+		m.Files.Reveal(path)
+	case RenameFilePrompt:
+		if strings.TrimSpace(msg.Value) == "" {
+			return
+		}
+		err = m.Editor.RenameFile(msg.Data, m.Files.Resolve(msg.Value))
+	case DeleteFilePrompt:
+		if msg.Confirmed {
+			err = m.Editor.DeleteFile(msg.Data)
+		}
+	}
+	if err != nil {
+		m.ErrorMsg = err.Error()
+	}
+	m.refreshFiles()
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"edigo/pkg/editor"
+	"edigo/pkg/network"
+	"edigo/pkg/theme"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// GrepPanel searches the whole project. The pattern is typed at the top,
+// hits stream into the list below while the search runs.
+type GrepPanel struct {
+	Input     textinput.Model
+	List      *list.Model
+	Options   editor.SearchOptions
+	Theme     *theme.Theme
+	results   <-chan network.SearchHit
+	searching bool
+}
+
+type grepItem struct {
+	hit network.SearchHit
+}
+
+func (i grepItem) Title() string {
+	return fmt.Sprintf("%s:%d:%d", i.hit.Path, i.hit.Line+1, i.hit.Column+1)
+}
+func (i grepItem) Description() string { return strings.TrimSpace(i.hit.Text) }
+func (i grepItem) FilterValue() string { return i.hit.Path }
+
+// grepHitsMsg carries the hits that arrived since the last message.
+type grepHitsMsg struct {
+	results <-chan network.SearchHit
+	hits    []network.SearchHit
+	done    bool
+}
+
+func NewGrepPanel(theme *theme.Theme) GrepPanel {
+	input := textinput.New()
+	input.Prompt = "Search project: "
+	input.Placeholder = "pattern"
+
+	return GrepPanel{
+		Input: input,
+		List:  createList("Search in Project", []list.Item{}, theme),
+		Theme: theme,
+	}
+}
+
+func (g *GrepPanel) Start(results <-chan network.SearchHit) tea.Cmd {
+	g.results = results
+	g.searching = true
+	g.List.SetItems([]list.Item{})
+	g.Input.Blur()
+	return waitForHits(results)
+}
+
+// addHits appends streamed hits and keeps waiting until the search ends.
+func (g *GrepPanel) addHits(msg grepHitsMsg) tea.Cmd {
+	if msg.results != g.results {
+		return nil
+	}
+	for _, hit := range msg.hits {
+		g.List.InsertItem(len(g.List.Items()), grepItem{hit: hit})
+	}
+	if msg.done {
+		g.searching = false
+		return nil
+	}
+	return waitForHits(msg.results)
+}
+
+func (g *GrepPanel) Selected() (network.SearchHit, bool) {
+	item, ok := g.List.SelectedItem().(grepItem)
+	return item.hit, ok
+}
+
+func (g *GrepPanel) SetSize(width int, height int) {
+	g.Input.Width = width - len(g.Input.Prompt) - 20
+	g.List.SetWidth(width)
+	g.List.SetHeight(height - 2) // the input line and its status
+}
+
+func (g GrepPanel) View() string {
+	status := fmt.Sprintf("%d hits", len(g.List.Items()))
+	if g.searching {
+		status += ", searching..."
+	}
+	options := renderOption(g.Theme, ".*", g.Options.Regex) +
+		renderOption(g.Theme, "Aa", g.Options.CaseSensitive) +
+		renderOption(g.Theme, "\\b", g.Options.WholeWord)
+	header := g.Input.View() + "  " + options
+	return header + "\n" + g.Theme.StatusBarStyle.Render(status) + "\n" + g.List.View()
+}
+
+// waitForHits blocks for the next hit and then takes what else is ready,
+// so the list is not redrawn for every single line.
+func waitForHits(results <-chan network.SearchHit) tea.Cmd {
+	return func() tea.Msg {
+		hit, ok := <-results
+		if !ok {
+			return grepHitsMsg{results: results, done: true}
+		}
+		hits := []network.SearchHit{hit}
+		for len(hits) < 100 {
+			select {
+			case hit, ok := <-results:
+				if !ok {
+					return grepHitsMsg{results: results, hits: hits, done: true}
+				}
+				hits = append(hits, hit)
+			default:
+				return grepHitsMsg{results: results, hits: hits}
+			}
+		}
+		return grepHitsMsg{results: results, hits: hits}
+	}
+}
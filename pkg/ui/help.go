@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"edigo/pkg/keymap"
+	"edigo/pkg/theme"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// HelpOverlay lists the active key bindings over the whole window.
+type HelpOverlay struct {
+	Viewport viewport.Model
+	Theme    *theme.Theme
+}
+
+func NewHelpOverlay(theme *theme.Theme) HelpOverlay {
+	return HelpOverlay{Viewport: viewport.New(80, 24), Theme: theme}
+}
+
+func (h *HelpOverlay) SetSize(width int, height int) {
+	h.Viewport.Width = width
+	h.Viewport.Height = height - 2 // Reserve space for title and hint
+}
+
+// SetKeymap renders the bindings, conflicts first.
+func (h *HelpOverlay) SetKeymap(keys *keymap.Keymap) {
+	var b strings.Builder
+	for _, conflict := range keys.Conflicts {
+		b.WriteString(h.Theme.RenderError("Conflict: "+conflict.String()) + "\n")
+	}
+	if len(keys.Conflicts) > 0 {
+		b.WriteString("\n")
+	}
+	for _, command := range keys.Commands {
+		bound := strings.Join(keys.Keys(command.Name), ", ")
+		if bound == "" {
+			bound = "unbound"
+		}
+		fmt.Fprintf(&b, "  %-24s %-22s %s\n", bound, command.Name, command.Description)
+	}
+	if path := keymap.DefaultPath(); path != "" {
+		fmt.Fprintf(&b, "\n  Rebind keys in %s, e.g. {\"profile\": \"emacs\", \"save\": [\"ctrl+s\", \"f2\"]}\n", path)
+	}
+	h.Viewport.SetContent(b.String())
+	h.Viewport.GotoTop()
+}
+
+func (h HelpOverlay) Update(msg tea.Msg) (HelpOverlay, tea.Cmd) {
+	var cmd tea.Cmd
+	h.Viewport, cmd = h.Viewport.Update(msg)
+	return h, cmd
+}
+
+func (h HelpOverlay) View() string {
+	return h.Theme.RenderMenuTitle("Key Bindings") + "\n" +
+		h.Viewport.View() + "\n" +
+		h.Theme.RenderFooter("Scroll with the arrow keys, esc closes")
+}
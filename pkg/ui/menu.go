@@ -1,9 +1,13 @@
 package ui
 
 import (
+	"edigo/pkg/editor"
+	"edigo/pkg/keymap"
 	"edigo/pkg/network"
 	"edigo/pkg/theme"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -12,6 +16,7 @@ import (
 type MenuItem struct {
 	title string
 	desc  string
+	value string
 }
 
 func (i MenuItem) Title() string       { return i.title }
@@ -19,17 +24,17 @@ func (i MenuItem) Description() string { return i.desc }
 func (i MenuItem) FilterValue() string { return i.title }
 
 type MenuModel struct {
-	lists    map[string]*list.Model
-	current  string
-	quitting bool
-	Theme    *theme.Theme
+	lists       map[string]*list.Model
+	current     string
+	quitting    bool
+	Theme       *theme.Theme
+	participant string // address of the participant picked in the participants list
 }
 
 type MenuAction string
 
 const (
 	NoAction                   MenuAction = "no_action"
-	SaveAction                 MenuAction = "save"
 	QuitAction                 MenuAction = "quit"
 	CreateSessionAction        MenuAction = "create_session"
 	CreatePublicSessionAction  MenuAction = "create_public_session"
@@ -37,6 +42,14 @@ const (
 	JoinSessionAction          MenuAction = "join_session"
 	BackToEditorAction         MenuAction = "back_to_editor"
 	BackToMainMenuAction       MenuAction = "back_to_main_menu"
+	KickAction                 MenuAction = "kick"
+	KickBanIDAction            MenuAction = "kick_ban_id"
+	KickBanIPAction            MenuAction = "kick_ban_ip"
+	FollowAction               MenuAction = "follow"
+	OpenDocumentAction         MenuAction = "open_document"
+	ToggleShareAction          MenuAction = "toggle_share"
+	StopFollowingAction        MenuAction = "stop_following"
+	CommandAction              MenuAction = "command"
 )
 
 type MenuMsg struct {
@@ -44,14 +57,10 @@ type MenuMsg struct {
 	Data   string
 }
 
-func NewMenuModel(theme *theme.Theme) MenuModel {
-	mainItems := []list.Item{
-		MenuItem{title: "Create Session", desc: "Start a new editing session"},
-		MenuItem{title: "Join Session", desc: "Join an existing editing session"},
-		MenuItem{title: "Save", desc: "Save the current file"},
-		MenuItem{title: "Back to Editor", desc: "Return to the editor"},
-		MenuItem{title: "Quit", desc: "Exit the editor"},
-	}
+func NewMenuModel(theme *theme.Theme, keys *keymap.Keymap) MenuModel {
+	mainItems := commandItems(keys, mainMenu)
+	mainItems = append(mainItems, MenuItem{title: "Back to Editor", desc: "Return to the editor"})
+	mainItems = append(mainItems, commandItems(keys, []string{"quit"})...)
 
 	joinItems := []list.Item{}
 
@@ -63,21 +72,59 @@ func NewMenuModel(theme *theme.Theme) MenuModel {
 		MenuItem{title: "Quit", desc: "Exit the editor"},
 	}
 
+	participantItems := []list.Item{
+		MenuItem{title: "Kick", desc: "Disconnect the user from the session"},
+		MenuItem{title: "Kick and Ban ID", desc: "Disconnect the user and refuse their peer ID"},
+		MenuItem{title: "Kick and Ban IP", desc: "Disconnect the user and refuse their IP address"},
+		MenuItem{title: "Back to Participants", desc: "Return to the participant list"},
+		MenuItem{title: "Back to Editor", desc: "Return to the editor"},
+	}
+
 	mainList := createList("Main Menu", mainItems, theme)
 	joinList := createList("Join Session", joinItems, theme)
+	participantsList := createList("Participants", []list.Item{}, theme)
+	participantList := createList("Participant", participantItems, theme)
+	followList := createList("Follow User", []list.Item{}, theme)
+	documentsList := createList("Open Shared File", []list.Item{}, theme)
+	shareList := createList("Share Files", []list.Item{}, theme)
+	buffersList := createList("Open Files", []list.Item{}, theme)
 	createList := createList("Create Session", createItems, theme)
 
 	return MenuModel{
 		lists: map[string]*list.Model{
-			"main":   mainList,
-			"join":   joinList,
-			"create": createList,
+			"main":         mainList,
+			"join":         joinList,
+			"create":       createList,
+			"participants": participantsList,
+			"participant":  participantList,
+			"follow":       followList,
+			"documents":    documentsList,
+			"share":        shareList,
+			"buffers":      buffersList,
 		},
 		current: "main",
 		Theme:   theme,
 	}
 }
 
+// commandItems lists registered commands with their keys. Picking one runs
+// the command like its key would.
+func commandItems(keys *keymap.Keymap, names []string) []list.Item {
+	items := []list.Item{}
+	for _, name := range names {
+		command, ok := keys.Find(name)
+		if !ok {
+			continue
+		}
+		desc := command.Description
+		if bound := keys.Keys(name); len(bound) > 0 {
+			desc += " (" + strings.Join(bound, ", ") + ")"
+		}
+		items = append(items, MenuItem{title: command.Label(), desc: desc, value: name})
+	}
+	return items
+}
+
 func createList(title string, items []list.Item, theme *theme.Theme) *list.Model {
 	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
 	l.Title = title
@@ -96,6 +143,7 @@ func (m MenuModel) Init() tea.Cmd {
 
 func (m MenuModel) Update(msg tea.Msg, network *network.Network) (MenuModel, tea.Cmd) {
 	m.setSessions(network)
+	m.setParticipants(network)
 
 	var cmd tea.Cmd
 	*m.lists[m.current], cmd = m.lists[m.current].Update(msg)
@@ -120,15 +168,22 @@ func (m MenuModel) handleEnter() (MenuModel, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.current == "main" && item.value != "" {
+		return m, func() tea.Msg { return MenuMsg{Action: CommandAction, Data: item.value} }
+	}
+
 	switch item.title {
-	case "Create Session":
-		m.current = "create"
-		return m, nil
-	case "Join Session":
-		m.current = "join"
+	case "Back to Participants":
+		m.current = "participants"
 		return m, nil
-	case "Save":
-		return m, func() tea.Msg { return MenuMsg{Action: SaveAction} }
+	case "Stop Following":
+		return m, func() tea.Msg { return MenuMsg{Action: StopFollowingAction} }
+	case "Kick":
+		return m, m.participantAction(KickAction)
+	case "Kick and Ban ID":
+		return m, m.participantAction(KickBanIDAction)
+	case "Kick and Ban IP":
+		return m, m.participantAction(KickBanIPAction)
 	case "Back to Editor":
 		return m, func() tea.Msg { return MenuMsg{Action: BackToEditorAction} }
 	case "Quit":
@@ -144,11 +199,34 @@ func (m MenuModel) handleEnter() (MenuModel, tea.Cmd) {
 		if m.current == "join" {
 			return m, func() tea.Msg { return MenuMsg{Action: JoinSessionAction, Data: item.title} }
 		}
+		if (m.current == "documents" || m.current == "buffers") && item.value != "" {
+			path := item.value
+			return m, func() tea.Msg { return MenuMsg{Action: OpenDocumentAction, Data: path} }
+		}
+		if m.current == "share" && item.value != "" {
+			path := item.value
+			return m, func() tea.Msg { return MenuMsg{Action: ToggleShareAction, Data: path} }
+		}
+		if m.current == "follow" && item.value != "" {
+			id := item.value
+			return m, func() tea.Msg { return MenuMsg{Action: FollowAction, Data: id} }
+		}
+		if m.current == "participants" && item.value != "" {
+			m.participant = item.value
+			m.lists["participant"].Title = "Participant " + item.title
+			m.current = "participant"
+			return m, nil
+		}
 	}
 
 	return m, nil
 }
 
+func (m MenuModel) participantAction(action MenuAction) tea.Cmd {
+	addr := m.participant
+	return func() tea.Msg { return MenuMsg{Action: action, Data: addr} }
+}
+
 func (m MenuModel) View() string {
 	return m.lists[m.current].View()
 }
@@ -165,3 +243,88 @@ func (m *MenuModel) setSessions(network *network.Network) {
 
 	m.lists["join"].SetItems(sessionItems)
 }
+
+func (m *MenuModel) setParticipants(network *network.Network) {
+	participantItems := []list.Item{}
+
+	if network.IsHost {
+		for _, participant := range network.Participants() {
+			title := participant.ID
+			if title == "" {
+				title = "unknown peer"
+			}
+			participantItems = append(participantItems, MenuItem{title: title, desc: "IP: " + participant.Addr, value: participant.Addr})
+		}
+	}
+
+	participantItems = append(participantItems, MenuItem{title: "Back to Main Menu", desc: "Return to main menu"})
+	participantItems = append(participantItems, MenuItem{title: "Back to Editor", desc: "Return to the editor"})
+
+	m.lists["participants"].SetItems(participantItems)
+}
+
+func (m *MenuModel) SetFollowUsers(users map[string]editor.CursorInfo) {
+	ids := make([]string, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return users[ids[i]].Username < users[ids[j]].Username })
+
+	followItems := []list.Item{}
+	for _, id := range ids {
+		followItems = append(followItems, MenuItem{title: users[id].Username, desc: "Peer: " + id, value: id})
+	}
+
+	followItems = append(followItems, MenuItem{title: "Stop Following", desc: "Scroll on your own again"})
+	followItems = append(followItems, MenuItem{title: "Back to Main Menu", desc: "Return to main menu"})
+	followItems = append(followItems, MenuItem{title: "Back to Editor", desc: "Return to the editor"})
+
+	m.lists["follow"].SetItems(followItems)
+}
+
+func (m *MenuModel) SetDocuments(shared []string, projectFiles []string, isHost bool) {
+	documentItems := []list.Item{}
+	for _, path := range shared {
+		documentItems = append(documentItems, MenuItem{title: path, desc: "Shared file", value: path})
+	}
+	documentItems = append(documentItems, MenuItem{title: "Back to Main Menu", desc: "Return to main menu"})
+	documentItems = append(documentItems, MenuItem{title: "Back to Editor", desc: "Return to the editor"})
+	m.lists["documents"].SetItems(documentItems)
+
+	sharedSet := make(map[string]bool, len(shared))
+	for _, path := range shared {
+		sharedSet[path] = true
+	}
+
+	shareItems := []list.Item{}
+	if isHost {
+		for _, path := range projectFiles {
+			desc := "Not shared, select to share"
+			if sharedSet[path] {
+				desc = "[shared] select to stop sharing"
+			}
+			shareItems = append(shareItems, MenuItem{title: path, desc: desc, value: path})
+		}
+	}
+	shareItems = append(shareItems, MenuItem{title: "Back to Main Menu", desc: "Return to main menu"})
+	shareItems = append(shareItems, MenuItem{title: "Back to Editor", desc: "Return to the editor"})
+	m.lists["share"].SetItems(shareItems)
+}
+
+// SetBuffers lists the files open as tabs.
+func (m *MenuModel) SetBuffers(buffers []string, current string, modified func(string) bool) {
+	bufferItems := []list.Item{}
+	for _, path := range buffers {
+		desc := "Open file"
+		if path == current {
+			desc = "Current file"
+		}
+		if modified(path) {
+			desc += ", unsaved changes"
+		}
+		bufferItems = append(bufferItems, MenuItem{title: path, desc: desc, value: path})
+	}
+	bufferItems = append(bufferItems, MenuItem{title: "Back to Main Menu", desc: "Return to main menu"})
+	bufferItems = append(bufferItems, MenuItem{title: "Back to Editor", desc: "Return to the editor"})
+	m.lists["buffers"].SetItems(bufferItems)
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"edigo/pkg/keymap"
+	"edigo/pkg/theme"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+)
+
+// CommandPalette lists every registered command with its keys and narrows
+// them down by fuzzy matching what is typed.
+type CommandPalette struct {
+	Input    textinput.Model
+	Theme    *theme.Theme
+	commands []keymap.Command
+	keys     *keymap.Keymap
+	matches  []keymap.Command
+	selected int
+	height   int
+}
+
+// paletteSource matches a query against the labels of the commands, or
+// their names.
+type paletteSource struct {
+	commands []keymap.Command
+	names    bool
+}
+
+func (s paletteSource) String(i int) string {
+	if s.names {
+		return s.commands[i].Name
+	}
+	return s.commands[i].Label()
+}
+func (s paletteSource) Len() int { return len(s.commands) }
+
+func NewCommandPalette(theme *theme.Theme) CommandPalette {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "command"
+
+	return CommandPalette{Input: input, Theme: theme, height: 24}
+}
+
+// Open starts with an empty query that lists all commands.
+func (p *CommandPalette) Open(keys *keymap.Keymap) tea.Cmd {
+	p.keys = keys
+	p.commands = keys.Commands
+	p.Input.Reset()
+	p.filter()
+	return p.Input.Focus()
+}
+
+func (p *CommandPalette) Close() {
+	p.Input.Blur()
+}
+
+func (p *CommandPalette) SetSize(width int, height int) {
+	p.Input.Width = width - len(p.Input.Prompt) - 1
+	p.height = height
+}
+
+// Selected is the name of the highlighted command.
+func (p *CommandPalette) Selected() (string, bool) {
+	if p.selected >= len(p.matches) {
+		return "", false
+	}
+	return p.matches[p.selected].Name, true
+}
+
+func (p *CommandPalette) filter() {
+	p.selected = 0
+	query := strings.TrimSpace(p.Input.Value())
+	if query == "" {
+		p.matches = p.commands
+		return
+	}
+	// A command matches if its label or its name does, ranked by the better.
+	scores := map[int]int{}
+	for _, names := range []bool{false, true} {
+		for _, match := range fuzzy.FindFrom(query, paletteSource{commands: p.commands, names: names}) {
+			if score, ok := scores[match.Index]; !ok || match.Score > score {
+				scores[match.Index] = match.Score
+			}
+		}
+	}
+	indexes := make([]int, 0, len(scores))
+	for i := range scores {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		if scores[indexes[a]] != scores[indexes[b]] {
+			return scores[indexes[a]] > scores[indexes[b]]
+		}
+		return indexes[a] < indexes[b]
+	})
+	p.matches = make([]keymap.Command, len(indexes))
+	for i, index := range indexes {
+		p.matches[i] = p.commands[index]
+	}
+}
+
+func (p CommandPalette) Update(msg tea.Msg) (CommandPalette, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.Type {
+		case tea.KeyUp, tea.KeyCtrlP:
+			if p.selected > 0 {
+				p.selected--
+			}
+			return p, nil
+		case tea.KeyDown, tea.KeyCtrlN:
+			if p.selected < len(p.matches)-1 {
+				p.selected++
+			}
+			return p, nil
+		}
+	}
+
+	query := p.Input.Value()
+	var cmd tea.Cmd
+	p.Input, cmd = p.Input.Update(msg)
+	if p.Input.Value() != query {
+		p.filter()
+	}
+	return p, cmd
+}
+
+func (p CommandPalette) View() string {
+	var b strings.Builder
+	b.WriteString(p.Theme.RenderMenuTitle("Command Palette") + "\n")
+	b.WriteString(p.Input.View() + "\n")
+
+	rows := p.height - 3 // title, input and hint
+	first := 0
+	if p.selected >= rows {
+		first = p.selected - rows + 1
+	}
+	for i := first; i < len(p.matches) && i < first+rows; i++ {
+		command := p.matches[i]
+		line := fmt.Sprintf("%-48s %s", command.Label(), strings.Join(p.keys.Keys(command.Name), ", "))
+		b.WriteString(p.Theme.RenderMenuItem(line, i == p.selected) + "\n")
+	}
+	if len(p.matches) == 0 {
+		b.WriteString("  No matching command\n")
+	}
+	b.WriteString(p.Theme.RenderFooter("Enter runs the command, esc closes"))
+	return b.String()
+}
@@ -0,0 +1,219 @@
+package ui
+
+import (
+	"edigo/pkg/editor"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type splitKind int
+
+const (
+	noSplit      splitKind = iota
+	splitRows              // horizontal split, one pane above the other
+	splitColumns           // vertical split, panes side by side
+)
+
+const (
+	paneResizeStep = 0.05
+	minPaneRatio   = 0.1
+	minPaneHeight  = 3 // header, one line of text and the status bar
+	minPaneWidth   = 10
+)
+
+// pane is a window on a buffer, or a split in two smaller panes. Leaves
+// keep the view they show, the focused one is live in the editor instead.
+type pane struct {
+	view          editor.View
+	split         splitKind
+	first, second *pane
+	parent        *pane
+	ratio         float64 // share of the first pane in a split
+	x, y          int
+	width, height int
+}
+
+// Panes is the tree of split windows over the editor area.
+type Panes struct {
+	root    *pane
+	focused *pane
+}
+
+func NewPanes() Panes {
+	root := &pane{}
+	return Panes{root: root, focused: root}
+}
+
+func (p *pane) leaves() []*pane {
+	if p.split == noSplit {
+		return []*pane{p}
+	}
+	return append(p.first.leaves(), p.second.leaves()...)
+}
+
+func (ps *Panes) Count() int {
+	return len(ps.root.leaves())
+}
+
+// splitFocused splits the focused pane in two showing the same view, the
+// new pane gets the focus.
+func (ps *Panes) splitFocused(kind splitKind, view editor.View) {
+	f := ps.focused
+	f.split = kind
+	f.ratio = 0.5
+	f.first = &pane{view: view, parent: f}
+	f.second = &pane{view: view, parent: f}
+	ps.focused = f.second
+}
+
+// closeFocused removes the focused pane, its sibling takes its place. It
+// returns the pane to focus next, nil for the last pane.
+func (ps *Panes) closeFocused() *pane {
+	f := ps.focused
+	parent := f.parent
+	if parent == nil {
+		return nil
+	}
+	sibling := parent.first
+	if sibling == f {
+		sibling = parent.second
+	}
+	x, y, width, height := parent.x, parent.y, parent.width, parent.height
+	*parent = pane{
+		view: sibling.view, split: sibling.split, first: sibling.first, second: sibling.second,
+		parent: parent.parent, ratio: sibling.ratio, x: x, y: y, width: width, height: height,
+	}
+	if parent.split != noSplit {
+		parent.first.parent = parent
+		parent.second.parent = parent
+	}
+	return parent.leaves()[0]
+}
+
+// only closes every pane but the focused one.
+func (ps *Panes) only() {
+	ps.root = ps.focused
+	ps.root.parent = nil
+}
+
+// resizeFocused grows the focused pane, or shrinks it for a negative step,
+// by moving the closest split line.
+func (ps *Panes) resizeFocused(step float64) {
+	child := ps.focused
+	parent := child.parent
+	if parent == nil {
+		return
+	}
+	if parent.second == child {
+		step = -step
+	}
+	parent.ratio = min(max(parent.ratio+step, minPaneRatio), 1-minPaneRatio)
+}
+
+// neighbour is the pane delta places on from the focused one, wrapping
+// around.
+func (ps *Panes) neighbour(delta int) *pane {
+	leaves := ps.root.leaves()
+	for i, leaf := range leaves {
+		if leaf == ps.focused {
+			return leaves[((i+delta)%len(leaves)+len(leaves))%len(leaves)]
+		}
+	}
+	return ps.focused
+}
+
+// at returns the pane below a cell of the editor area.
+func (ps *Panes) at(x int, y int) *pane {
+	for _, leaf := range ps.root.leaves() {
+		if x >= leaf.x && x < leaf.x+leaf.width && y >= leaf.y && y < leaf.y+leaf.height {
+			return leaf
+		}
+	}
+	return ps.focused
+}
+
+// layout places the panes in an area, side by side panes are separated by
+// a line of one cell.
+func (p *pane) layout(x int, y int, width int, height int) {
+	p.x, p.y, p.width, p.height = x, y, width, height
+	switch p.split {
+	case splitRows:
+		top := clampSize(int(float64(height)*p.ratio+0.5), minPaneHeight, height)
+		p.first.layout(x, y, width, top)
+		p.second.layout(x, y+top, width, height-top)
+	case splitColumns:
+		left := clampSize(int(float64(width-1)*p.ratio+0.5), minPaneWidth, width-1)
+		p.first.layout(x, y, left, height)
+		p.second.layout(x+left+1, y, width-left-1, height)
+	}
+}
+
+// clampSize keeps both sides of a split at least min cells, if there is
+// room for that.
+func clampSize(size int, least int, total int) int {
+	if total < 2*least {
+		return total / 2
+	}
+	return max(least, min(size, total-least))
+}
+
+func (m *UIModel) splitPane(kind splitKind) {
+	m.Panes.splitFocused(kind, m.Editor.CurrentView())
+	m.layout()
+}
+
+// focusPane parks the view of the focused pane and makes another one live.
+func (m *UIModel) focusPane(p *pane) {
+	if p == m.Panes.focused {
+		return
+	}
+	m.Panes.focused.view = m.Editor.CurrentView()
+	m.Panes.focused = p
+	m.Editor.ShowView(p.view)
+	m.layout()
+}
+
+func (m *UIModel) closePane() {
+	next := m.Panes.closeFocused()
+	if next == nil {
+		m.ErrorMsg = "Cannot close the last pane"
+		return
+	}
+	m.Panes.focused = next
+	m.Editor.ShowView(next.view)
+	m.layout()
+}
+
+func (m *UIModel) onlyPane() {
+	m.Panes.only()
+	m.layout()
+}
+
+func (m *UIModel) resizePane(step float64) {
+	m.Panes.resizeFocused(step)
+	m.layout()
+}
+
+// forgetDocument points the panes that show a closed file at the open one.
+func (m *UIModel) forgetDocument(path string) {
+	for _, leaf := range m.Panes.root.leaves() {
+		if leaf != m.Panes.focused && leaf.view.Document == path {
+			leaf.view = m.Editor.CurrentView()
+		}
+	}
+}
+
+// render draws the panes, the focused one as drawn into the viewport.
+func (ps *Panes) render(p *pane, focused string, e *editor.Editor, separator func(int) string) string {
+	switch p.split {
+	case splitRows:
+		return lipgloss.JoinVertical(lipgloss.Left, ps.render(p.first, focused, e, separator), ps.render(p.second, focused, e, separator))
+	case splitColumns:
+		return lipgloss.JoinHorizontal(lipgloss.Top, ps.render(p.first, focused, e, separator), separator(p.height), ps.render(p.second, focused, e, separator))
+	}
+	content := focused
+	if p != ps.focused {
+		content = e.RenderView(p.view, p.width, p.height)
+	}
+	return lipgloss.NewStyle().Width(p.width).Height(p.height).MaxWidth(p.width).MaxHeight(p.height).Render(content)
+}
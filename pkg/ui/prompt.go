@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"edigo/pkg/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type PromptKind int
+
+const (
+	NoPrompt PromptKind = iota
+	SaveCopyPrompt
+	ConfirmHostSavePrompt
+	ReplaceCopyPrompt
+	GoToLinePrompt
+	OpenFilePrompt
+	CloseBufferPrompt
+	NewFilePrompt
+	RenameFilePrompt
+	DeleteFilePrompt
+	SaveAsPrompt
+	ReplaceFilePrompt
+	OverwritePrompt
+)
+
+// Prompt is a one line question shown in place of the footer. Confirmation
+// prompts take a single y or n, all others read a line of text.
+type Prompt struct {
+	Kind    PromptKind
+	Title   string
+	Input   textinput.Model
+	Confirm bool
+	Data    string
+	Theme   *theme.Theme
+}
+
+type PromptMsg struct {
+	Kind      PromptKind
+	Value     string
+	Confirmed bool
+	Data      string
+}
+
+func NewPrompt(kind PromptKind, title string, value string, theme *theme.Theme) Prompt {
+	input := textinput.New()
+	input.Prompt = title + " "
+	input.SetValue(value)
+	input.CursorEnd()
+	input.Focus()
+
+	return Prompt{Kind: kind, Title: title, Input: input, Theme: theme}
+}
+
+func NewConfirmPrompt(kind PromptKind, title string, data string, theme *theme.Theme) Prompt {
+	return Prompt{Kind: kind, Title: title + " (y/n)", Confirm: true, Data: data, Theme: theme}
+}
+
+// Update returns done once the prompt was answered or cancelled with esc.
+// Cancelled prompts produce no message.
+func (p Prompt) Update(msg tea.Msg) (Prompt, tea.Cmd, bool) {
+	keyMsg, isKey := msg.(tea.KeyMsg)
+
+	if p.Confirm {
+		if !isKey {
+			return p, nil, false
+		}
+		switch keyMsg.String() {
+		case "y", "Y":
+			return p, p.result("", true), true
+		case "n", "N", "esc":
+			return p, p.result("", false), true
+		}
+		return p, nil, false
+	}
+
+	if isKey {
+		switch keyMsg.Type {
+		case tea.KeyEsc:
+			return p, nil, true
+		case tea.KeyEnter:
+			return p, p.result(p.Input.Value(), true), true
+		}
+	}
+
+	var cmd tea.Cmd
+	p.Input, cmd = p.Input.Update(msg)
+	return p, cmd, false
+}
+
+func (p Prompt) result(value string, confirmed bool) tea.Cmd {
+	msg := PromptMsg{Kind: p.Kind, Value: value, Confirmed: confirmed, Data: p.Data}
+	return func() tea.Msg { return msg }
+}
+
+func (p Prompt) View() string {
+	if p.Confirm {
+		return p.Theme.RenderError(p.Title)
+	}
+	return p.Input.View()
+}
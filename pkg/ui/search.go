@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"edigo/pkg/editor"
+	"edigo/pkg/theme"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SearchBar is the find and replace line shown in place of the footer.
+// Typing in the find field searches incrementally.
+type SearchBar struct {
+	Find        textinput.Model
+	Replace     textinput.Model
+	Options     editor.SearchOptions
+	ShowReplace bool
+	Theme       *theme.Theme
+}
+
+func NewSearchBar(theme *theme.Theme) SearchBar {
+	find := textinput.New()
+	find.Prompt = "Find: "
+	find.Width = 24
+
+	replace := textinput.New()
+	replace.Prompt = "Replace: "
+	replace.Width = 24
+
+	return SearchBar{Find: find, Replace: replace, Theme: theme}
+}
+
+// Open focuses the find field, or the replace field when asked to replace
+// and there is already something to find.
+func (s *SearchBar) Open(replace bool) tea.Cmd {
+	s.ShowReplace = s.ShowReplace || replace
+	if replace && s.Find.Value() != "" {
+		s.Find.Blur()
+		return s.Replace.Focus()
+	}
+	s.Replace.Blur()
+	return s.Find.Focus()
+}
+
+func (s *SearchBar) Close() {
+	s.Find.Blur()
+	s.Replace.Blur()
+	s.ShowReplace = false
+}
+
+func (s *SearchBar) toggleField() tea.Cmd {
+	if s.Find.Focused() {
+		s.ShowReplace = true
+		s.Find.Blur()
+		return s.Replace.Focus()
+	}
+	s.Replace.Blur()
+	return s.Find.Focus()
+}
+
+func (s SearchBar) Update(msg tea.Msg) (SearchBar, tea.Cmd) {
+	var findCmd, replaceCmd tea.Cmd
+	s.Find, findCmd = s.Find.Update(msg)
+	s.Replace, replaceCmd = s.Replace.Update(msg)
+	return s, tea.Batch(findCmd, replaceCmd)
+}
+
+func (s SearchBar) View(matches int, current int, err error) string {
+	parts := []string{s.Find.View()}
+	if s.ShowReplace {
+		parts = append(parts, s.Replace.View())
+	}
+
+	status := fmt.Sprintf("%d/%d", current, matches)
+	if err != nil {
+		status = s.Theme.RenderError("invalid pattern")
+	} else if matches == 0 && s.Find.Value() != "" {
+		status = s.Theme.RenderError("no matches")
+	}
+	parts = append(parts, status, renderOption(s.Theme, ".*", s.Options.Regex)+renderOption(s.Theme, "Aa", s.Options.CaseSensitive)+renderOption(s.Theme, "\\b", s.Options.WholeWord))
+	return strings.Join(parts, "  ")
+}
+
+// renderOption shows a search toggle, highlighted while it is on.
+func renderOption(theme *theme.Theme, label string, enabled bool) string {
+	if enabled {
+		return theme.CurrentMatchStyle.Render("[" + label + "]")
+	}
+	return "[" + label + "]"
+}
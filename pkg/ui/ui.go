@@ -1,56 +1,92 @@
 package ui
 
 import (
+	"edigo/pkg/clipboard"
 	"edigo/pkg/editor"
+	"edigo/pkg/keymap"
+	"edigo/pkg/network"
 	"edigo/pkg/theme"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type UIModel struct {
-	Editor         *editor.Editor
-	InputHandler   *editor.InputHandler
-	Viewport       viewport.Model
-	SaveKey        key.Binding
-	MenuKey        key.Binding
-	Menu           MenuModel
-	ShowMenu       bool
-	UnsavedChanges bool
-	Theme          *theme.Theme
-	ErrorMsg       string
-}
-
-func NewUIModel(content string, filePath string) *UIModel {
+	Editor       *editor.Editor
+	InputHandler *editor.InputHandler
+	Viewport     viewport.Model
+	Keymap       *keymap.Keymap
+	pendingKeys  []string // start of a key sequence like ctrl+x ctrl+s
+	Menu         MenuModel
+	ShowMenu     bool
+	Chat         ChatPanel
+	ShowChat     bool
+	Prompt       Prompt
+	ShowPrompt   bool
+	Search       SearchBar
+	ShowSearch   bool
+	Grep         GrepPanel
+	ShowGrep     bool
+	Help         HelpOverlay
+	ShowHelp     bool
+	Palette      CommandPalette
+	ShowPalette  bool
+	Panes        Panes
+	Files        FileBrowser
+	ShowFiles    bool
+	saveRequest  editor.SaveRequest
+	overwriting  *editor.SaveRequest // guest request behind the overwrite prompt
+	conflicts    []string            // files Save All found changed on disk, asked about one by one
+	Theme        *theme.Theme
+	ErrorMsg     string
+	width        int
+	height       int
+}
+
+// NewUIModel opens a file with the keys of a profile, which may be empty to
+// use the one of the keymap file.
+func NewUIModel(content string, filePath string, profile string) *UIModel {
 	siteID := generateSiteID()
 	theme := theme.NewTheme()
 	editorInstance := editor.NewEditor(content, filePath, siteID, theme)
 	vp := viewport.New(80, 24)
 	editorInstance.Viewport = vp
 	editorInstance.FilePath = filePath
+	keys, keymapErr := loadKeymap(profile)
 
-	return &UIModel{
+	m := &UIModel{
 		Editor:       editorInstance,
-		InputHandler: editor.NewInputHandler(editorInstance),
+		InputHandler: editor.NewInputHandler(editorInstance, keys),
 		Viewport:     vp,
-		SaveKey: key.NewBinding(
-			key.WithKeys("ctrl+s"),
-			key.WithHelp("ctrl+s", "save"),
-		),
-		MenuKey: key.NewBinding(
-			key.WithKeys("esc"),
-			key.WithHelp("esc", "menu"),
-		),
-		Menu:           NewMenuModel(theme),
-		ShowMenu:       false,
-		UnsavedChanges: false,
-		Theme:          theme,
-		ErrorMsg:       "",
+		Keymap:       keys,
+		Menu:         NewMenuModel(theme, keys),
+		ShowMenu:     false,
+		Chat:         NewChatPanel(theme),
+		ShowChat:     false,
+		Search:       NewSearchBar(theme),
+		Grep:         NewGrepPanel(theme),
+		Help:         NewHelpOverlay(theme),
+		Palette:      NewCommandPalette(theme),
+		Panes:        NewPanes(),
+		Files:        NewFileBrowser(theme),
+		Theme:        theme,
+		ErrorMsg:     keymapErr,
+	}
+	if keys.Profile == "vim" {
+		m.InputHandler.SetVim(true)
 	}
+	return m
 }
 
 func (m *UIModel) Init() tea.Cmd {
@@ -62,33 +98,113 @@ func (m *UIModel) Init() tea.Cmd {
 }
 
 func (m *UIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, remote := msg.(editor.RemoteChange); remote {
+		// The network waits for renames, whichever panel is open.
+		m.Editor.ApplyRenames()
+	}
 	if m.ShowMenu {
 		return m.updateMenu(msg)
 	}
+	if m.ShowGrep {
+		return m.updateGrep(msg)
+	}
+	if m.ShowHelp {
+		return m.updateHelp(msg)
+	}
+	if m.ShowPalette {
+		return m.updatePalette(msg)
+	}
+
+	var promptCmd tea.Cmd
+	if m.ShowPrompt {
+		var done bool
+		m.Prompt, promptCmd, done = m.Prompt.Update(msg)
+		if done {
+			m.ShowPrompt = false
+		}
+		if _, isKey := msg.(tea.KeyMsg); isKey {
+			return m, promptCmd
+		}
+	}
 
 	var cmd tea.Cmd
 	m.Viewport, cmd = m.Viewport.Update(msg)
+	cmd = tea.Batch(cmd, promptCmd)
+
+	if _, isKey := msg.(tea.KeyMsg); !isKey && m.ShowChat {
+		var chatCmd tea.Cmd
+		m.Chat, chatCmd = m.Chat.Update(msg)
+		cmd = tea.Batch(cmd, chatCmd)
+	}
+	if _, isKey := msg.(tea.KeyMsg); !isKey && m.ShowSearch {
+		var searchCmd tea.Cmd
+		m.Search, searchCmd = m.Search.Update(msg)
+		cmd = tea.Batch(cmd, searchCmd)
+	}
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch {
-		case key.Matches(msg, m.SaveKey):
-			m.saveFile()
+		if m.ShowChat && m.Chat.Input.Focused() {
+			return m.updateChat(msg)
+		}
+		if m.ShowFiles && m.Files.Focused {
+			return m.updateFiles(msg)
+		}
+		if m.ShowSearch {
+			return m.updateSearch(msg)
+		}
+		if vim := m.InputHandler.Vim; vim != nil {
+			if commands, handled := vim.HandleKey(msg); handled {
+				m.Viewport.SetContent(m.Editor.RenderContent())
+				return m.runVimCommands(commands)
+			}
+		}
+
+		keys := append(append([]string{}, m.pendingKeys...), msg.String())
+		command, prefix := m.Keymap.Lookup(keys)
+		if prefix {
+			m.pendingKeys = keys
 			return m, nil
-		case key.Matches(msg, m.MenuKey):
-			m.ShowMenu = true
-			m.Menu.current = "main"
+		}
+		if m.pendingKeys != nil && command == "" {
+			m.pendingKeys = nil
+			if m.Keymap.Command(msg.String()) != "cancel" {
+				m.ErrorMsg = strings.Join(keys, " ") + " is not bound"
+			}
 			return m, nil
-		default:
+		}
+		m.pendingKeys = nil
+
+		if command == "" {
 			m.InputHandler.HandleKeyMsg(msg)
-			m.UnsavedChanges = true
+		} else {
+			cmd = tea.Batch(cmd, m.runCommand(command))
 		}
 
+	case tea.MouseMsg:
+		// Make the coordinates relative to the pane below our header.
+		msg.Y -= lipgloss.Height(m.Theme.RenderHeader(""))
+		if m.ShowFiles {
+			if msg.X < m.Files.Width {
+				m.fileBrowserMouse(msg)
+				break
+			}
+			msg.X -= m.Files.Width
+			if msg.Action == tea.MouseActionPress {
+				m.Files.Focused = false
+			}
+		}
+		if msg.Action == tea.MouseActionPress {
+			m.focusPane(m.Panes.at(msg.X, msg.Y))
+		}
+		msg.X -= m.Panes.focused.x
+		msg.Y -= m.Panes.focused.y
+		m.InputHandler.HandleMouseMsg(msg)
+
 	case tea.WindowSizeMsg:
-		m.Viewport.Width = msg.Width
-		m.Viewport.Height = msg.Height - 2 // Reserve space for header and footer
-		m.Editor.Viewport.Width = msg.Width
-		m.Editor.Viewport.Height = msg.Height - 2
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
 		for k := range m.Menu.lists {
 			m.Menu.lists[k].SetWidth(msg.Width)
 			m.Menu.lists[k].SetHeight(msg.Height)
@@ -100,23 +216,616 @@ func (m *UIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.ErrorMsg = ""
 		}
+		if m.ShowChat {
+			m.Editor.MarkChatRead()
+		}
+		if !m.ShowPrompt {
+			m.askPendingSave()
+		}
+		if m.ShowFiles {
+			// Guests learn about shared files through the host.
+			m.refreshFiles()
+		}
+
+	case PromptMsg:
+		m.handlePrompt(msg)
 	}
 
 	m.Viewport.SetContent(m.Editor.RenderContent())
 	return m, tea.Batch(cmd, waitForActivity(m.Editor.Update))
 }
 
+// runCommand runs a command of the registry. Its keys, the menu and the
+// command palette all end up here.
+func (m *UIModel) runCommand(name string) tea.Cmd {
+	switch name {
+	case "find", "find-next", "find-previous":
+		return m.openSearch(false)
+	case "replace":
+		return m.openSearch(true)
+	case "quit":
+		_, cmd := m.quit()
+		return cmd
+	case "search-project":
+		return m.openGrep()
+	case "go-to-line":
+		return m.openPrompt(NewPrompt(GoToLinePrompt, "Go to line[:column]:", "", m.Theme))
+	case "help":
+		m.openHelp()
+	case "command-palette":
+		return m.openPalette()
+	case "toggle-vim":
+		m.toggleVim()
+	case "toggle-chat":
+		_, cmd := m.toggleChat()
+		return cmd
+	case "save":
+		m.saveDocument(m.Editor.Document)
+	case "save-as":
+		return m.openPrompt(NewPrompt(SaveAsPrompt, "Save as:", m.Editor.FilePath, m.Theme))
+	case "save-copy":
+		// Spell out where the copy goes, guests see the host's paths.
+		name, err := filepath.Abs(filepath.Base(m.Editor.FilePath))
+		if err != nil {
+			name = filepath.Base(m.Editor.FilePath)
+		}
+		return m.openPrompt(NewPrompt(SaveCopyPrompt, "Save local copy as:", name, m.Theme))
+	case "ask-host-save":
+		m.Editor.RequestHostSave()
+	case "copy":
+		m.copySelection(false)
+	case "cut":
+		m.copySelection(true)
+	case "paste":
+		m.Editor.Paste(clipboard.Read())
+	case "open-menu":
+		if m.Editor.ExtraCursors() > 0 {
+			// Like in other editors, esc first goes back to one cursor.
+			m.Editor.DropCursors()
+			break
+		}
+		m.openMenu("main")
+	case "create-session":
+		m.openMenu("create")
+	case "join-session":
+		m.openMenu("join")
+	case "participants":
+		m.openMenu("participants")
+	case "follow-user":
+		m.openMenu("follow")
+	case "open-shared-file":
+		m.openMenu("documents")
+	case "share-files":
+		m.openMenu("share")
+	case "open-file":
+		return m.openPrompt(NewPrompt(OpenFilePrompt, "Open file:", "", m.Theme))
+	case "next-buffer":
+		m.Editor.NextBuffer(1)
+	case "previous-buffer":
+		m.Editor.NextBuffer(-1)
+	case "close-buffer":
+		if m.Editor.Modified() {
+			title := m.Editor.Document + " has unsaved changes. Close it anyway?"
+			return m.openPrompt(NewConfirmPrompt(CloseBufferPrompt, title, m.Editor.Document, m.Theme))
+		}
+		m.closeBuffer()
+	case "split-horizontal":
+		m.splitPane(splitRows)
+	case "split-vertical":
+		m.splitPane(splitColumns)
+	case "focus-next-pane":
+		m.focusPane(m.Panes.neighbour(1))
+	case "focus-previous-pane":
+		m.focusPane(m.Panes.neighbour(-1))
+	case "close-pane":
+		m.closePane()
+	case "only-pane":
+		m.onlyPane()
+	case "grow-pane":
+		m.resizePane(paneResizeStep)
+	case "shrink-pane":
+		m.resizePane(-paneResizeStep)
+	case "toggle-files":
+		m.toggleFiles()
+	case "list-buffers":
+		m.openMenu("buffers")
+	case "save-all":
+		m.saveAll()
+	case "toggle-share":
+		m.Editor.ToggleShared(m.Editor.Document)
+	default:
+		m.InputHandler.Run(name)
+	}
+	return nil
+}
+
+func (m *UIModel) toggleVim() {
+	m.InputHandler.SetVim(m.InputHandler.Vim == nil)
+	m.ErrorMsg = "Vim mode off"
+	if m.InputHandler.Vim != nil {
+		m.ErrorMsg = "Vim mode on"
+	}
+}
+
+// runVimCommands carries out what the Vim command line asked for.
+func (m *UIModel) runVimCommands(commands []string) (tea.Model, tea.Cmd) {
+	for _, command := range commands {
+		switch {
+		case command == "save" || command == "save-all":
+			m.runCommand(command)
+			if m.Editor.Modified() {
+				// Do not quit after a failed :wq.
+				return m, nil
+			}
+		case (command == "quit" || command == "quit!") && m.Panes.Count() > 1:
+			// Like in Vim, :q closes the pane while there are others.
+			m.closePane()
+		case command == "quit" || command == "quit-all":
+			if modified := m.Editor.ModifiedBuffers(); len(modified) > 0 {
+				m.ErrorMsg = "No write since last change of " + strings.Join(modified, ", ") + " (add ! to override)"
+				return m, nil
+			}
+			return m.quit()
+		case command == "quit!" || command == "quit-all!":
+			return m.quit()
+		case command == "close-buffer!":
+			m.closeBuffer()
+		case command == "save!" && (m.Editor.Network.CurrentSession == "" || m.Editor.Network.IsHost):
+			// Like :w! in Vim, overwrite a file changed on disk.
+			m.saved(m.Editor.Document, m.Editor.Overwrite(m.Editor.Document))
+		case command == "save!":
+			m.saveDocument(m.Editor.Document)
+		case strings.HasPrefix(command, "save-as "):
+			m.saveAs(strings.TrimPrefix(command, "save-as "), false)
+		case strings.HasPrefix(command, "edit "):
+			m.Editor.OpenFile(strings.TrimPrefix(command, "edit "))
+		default:
+			cmd := m.runCommand(command)
+			m.Viewport.SetContent(m.Editor.RenderContent())
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+func (m *UIModel) quit() (tea.Model, tea.Cmd) {
+	if modified := m.Editor.ModifiedBuffers(); len(modified) > 0 {
+		fmt.Println("Warning: You have unsaved changes in " + strings.Join(modified, ", ") + "!")
+	}
+	m.Editor.Stop()
+	return m, tea.Quit
+}
+
+func (m *UIModel) copySelection(cut bool) {
+	if !m.Editor.HasSelection() {
+		return
+	}
+	text := m.Editor.Copy()
+	if cut {
+		m.Editor.Cut()
+	}
+	if err := clipboard.Write(text); err != nil {
+		m.ErrorMsg = fmt.Sprintf("Error copying to clipboard: %v", err)
+	}
+}
+
+func (m *UIModel) openPrompt(prompt Prompt) tea.Cmd {
+	m.Prompt = prompt
+	m.ShowPrompt = true
+	if prompt.Confirm {
+		return nil
+	}
+	return textinput.Blink
+}
+
+func (m *UIModel) handlePrompt(msg PromptMsg) {
+	switch msg.Kind {
+	case SaveAsPrompt:
+		m.saveAs(msg.Value, false)
+	case ReplaceFilePrompt:
+		if msg.Confirmed {
+			m.saveAs(msg.Data, true)
+		} else {
+			m.ErrorMsg = "File not saved"
+		}
+	case SaveCopyPrompt:
+		m.saveLocalCopy(msg.Value, false)
+	case ReplaceCopyPrompt:
+		if msg.Confirmed {
+			m.saveLocalCopy(msg.Data, true)
+		} else {
+			m.ErrorMsg = "Copy not saved"
+		}
+	case GoToLinePrompt:
+		line, column, err := parseLineColumn(msg.Value)
+		if err != nil {
+			m.ErrorMsg = err.Error()
+			return
+		}
+		m.Editor.GoToLine(line, column)
+	case ConfirmHostSavePrompt:
+		if msg.Confirmed {
+			m.saveRequested(m.saveRequest)
+		} else {
+			m.Editor.DeclineSave(m.saveRequest)
+		}
+		if !m.ShowPrompt {
+			m.askPendingSave()
+		}
+		m.confirmNextConflict()
+	case OverwritePrompt:
+		request := m.overwriting
+		m.overwriting = nil
+		var err error
+		if msg.Confirmed {
+			err = m.Editor.Overwrite(msg.Data)
+			m.saved(msg.Data, err)
+		} else {
+			m.ErrorMsg = msg.Data + " was not saved"
+		}
+		if request != nil {
+			if !msg.Confirmed || err != nil {
+				m.Editor.DeclineSave(*request)
+			}
+			if !m.ShowPrompt {
+				m.askPendingSave()
+			}
+		}
+		m.confirmNextConflict()
+	case OpenFilePrompt:
+		if msg.Value != "" {
+			m.Editor.OpenFile(msg.Value)
+		}
+	case NewFilePrompt, RenameFilePrompt, DeleteFilePrompt:
+		m.handleFilePrompt(msg)
+	case CloseBufferPrompt:
+		if msg.Confirmed && msg.Data == m.Editor.Document {
+			m.closeBuffer()
+		}
+	}
+}
+
+// parseLineColumn reads "line" or "line:column", both starting at 1.
+func parseLineColumn(value string) (int, int, error) {
+	lineText, columnText, hasColumn := strings.Cut(strings.TrimSpace(value), ":")
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		return 0, 0, fmt.Errorf("Invalid line number: %q", lineText)
+	}
+	column := 1
+	if hasColumn {
+		column, err = strconv.Atoi(columnText)
+		if err != nil || column < 1 {
+			return 0, 0, fmt.Errorf("Invalid column: %q", columnText)
+		}
+	}
+	return line, column, nil
+}
+
+// askPendingSave shows the next save request a guest sent to us as host.
+func (m *UIModel) askPendingSave() {
+	request, ok := m.Editor.PendingSaveRequest()
+	if !ok {
+		return
+	}
+	m.saveRequest = request
+	title := fmt.Sprintf("%s asks you to save %s. Save now?", request.Username, request.Document)
+	m.openPrompt(NewConfirmPrompt(ConfirmHostSavePrompt, title, request.Document, m.Theme))
+}
+
+// layout sizes the editor to the window, leaving room for the chat panel.
+func (m *UIModel) layout() {
+	editorWidth := m.width
+	if m.ShowChat {
+		editorWidth -= m.Chat.Width
+	}
+	if m.ShowFiles {
+		editorWidth -= m.Files.Width
+	}
+	m.Panes.root.layout(0, 0, editorWidth, m.height-2) // Reserve space for header and footer
+	m.Viewport.Width = m.Panes.focused.width
+	m.Viewport.Height = m.Panes.focused.height
+	m.Editor.Viewport.Width = m.Panes.focused.width
+	m.Editor.Viewport.Height = m.Panes.focused.height
+	m.Chat.Height = m.height - 2
+	m.Files.Height = m.height - 2
+	m.Editor.SendViewportUpdate()
+	if m.Editor.Following == "" {
+		m.Editor.ScrollToCursor()
+	}
+}
+
+func (m *UIModel) toggleChat() (tea.Model, tea.Cmd) {
+	if m.ShowChat && m.Chat.Input.Focused() {
+		m.ShowChat = false
+		m.Chat.Input.Blur()
+	} else {
+		m.ShowChat = true
+		m.Editor.MarkChatRead()
+		m.Chat.Input.Focus()
+	}
+	m.layout()
+	m.Viewport.SetContent(m.Editor.RenderContent())
+	return m, textinputBlink(m.Chat.Input.Focused())
+}
+
+func (m *UIModel) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case m.Keymap.Command(msg.String()) == "toggle-chat":
+		return m.toggleChat()
+	case msg.Type == tea.KeyEsc:
+		// Hand the keyboard back to the editor but keep the panel open.
+		m.Chat.Input.Blur()
+		return m, nil
+	case msg.Type == tea.KeyEnter:
+		m.Editor.SendChat(m.Chat.Input.Value())
+		m.Chat.Input.Reset()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.Chat, cmd = m.Chat.Update(msg)
+	return m, cmd
+}
+
+func (m *UIModel) openSearch(replace bool) tea.Cmd {
+	m.ShowSearch = true
+	cmd := m.Search.Open(replace)
+	if query := m.Editor.SelectedText(); query != "" && !strings.Contains(query, "\n") {
+		m.Search.Find.SetValue(query)
+		m.Search.Find.CursorEnd()
+	}
+	m.Editor.SetSearch(m.Search.Find.Value(), m.Search.Options)
+	m.Viewport.SetContent(m.Editor.RenderContent())
+	return cmd
+}
+
+// updateSearch handles keys while the find bar is open. Enter finds the next
+// match or, in the replace field, replaces the current one.
+func (m *UIModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch {
+	case msg.Type == tea.KeyEsc || m.Keymap.Command(msg.String()) == "cancel":
+		m.ShowSearch = false
+		m.Search.Close()
+		m.Editor.ClearSearch()
+	case m.Keymap.Command(msg.String()) == "find" && m.Search.Find.Focused() && m.Search.Find.Value() != "":
+		// Like isearch, finding again goes on to the next match.
+		m.Editor.NextMatch()
+	case m.Keymap.Command(msg.String()) == "find":
+		cmd = m.Search.Open(false)
+	case m.Keymap.Command(msg.String()) == "find-next":
+		m.Editor.NextMatch()
+	case m.Keymap.Command(msg.String()) == "find-previous":
+		m.Editor.PrevMatch()
+	case m.Keymap.Command(msg.String()) == "replace":
+		cmd = m.Search.Open(true)
+	case msg.Type == tea.KeyTab:
+		cmd = m.Search.toggleField()
+	case msg.Type == tea.KeyUp:
+		m.Editor.PrevMatch()
+	case msg.Type == tea.KeyDown:
+		m.Editor.NextMatch()
+	case msg.Type == tea.KeyEnter:
+		if m.Search.Replace.Focused() {
+			m.Editor.ReplaceCurrent(m.Search.Replace.Value())
+		} else {
+			m.Editor.NextMatch()
+		}
+	case msg.String() == "alt+a":
+		count := m.Editor.ReplaceAll(m.Search.Replace.Value())
+		m.ErrorMsg = fmt.Sprintf("Replaced %d matches", count)
+	case msg.String() == "alt+r":
+		m.Search.Options.Regex = !m.Search.Options.Regex
+		m.Editor.SetSearch(m.Search.Find.Value(), m.Search.Options)
+	case msg.String() == "alt+c":
+		m.Search.Options.CaseSensitive = !m.Search.Options.CaseSensitive
+		m.Editor.SetSearch(m.Search.Find.Value(), m.Search.Options)
+	case msg.String() == "alt+w":
+		m.Search.Options.WholeWord = !m.Search.Options.WholeWord
+		m.Editor.SetSearch(m.Search.Find.Value(), m.Search.Options)
+	default:
+		query := m.Search.Find.Value()
+		m.Search, cmd = m.Search.Update(msg)
+		if m.Search.Find.Value() != query {
+			m.Editor.SetSearch(m.Search.Find.Value(), m.Search.Options)
+		}
+	}
+	m.Viewport.SetContent(m.Editor.RenderContent())
+	return m, cmd
+}
+
+func (m *UIModel) openGrep() tea.Cmd {
+	m.ShowGrep = true
+	m.Grep.SetSize(m.width, m.height)
+	if query := m.Editor.SelectedText(); query != "" && !strings.Contains(query, "\n") {
+		m.Grep.Input.SetValue(query)
+		m.Grep.Input.CursorEnd()
+	}
+	return m.Grep.Input.Focus()
+}
+
+func (m *UIModel) closeGrep() {
+	m.ShowGrep = false
+	m.Grep.Input.Blur()
+	m.Editor.StopGrep()
+	m.Viewport.SetContent(m.Editor.RenderContent())
+}
+
+// updateGrep handles the project search panel. Enter in the pattern field
+// starts a search, enter in the list opens the selected hit.
+func (m *UIModel) updateGrep(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case grepHitsMsg:
+		return m, m.Grep.addHits(msg)
+	case editor.RemoteChange:
+		return m, waitForActivity(m.Editor.Update)
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
+		m.Grep.SetSize(msg.Width, msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m.closeGrep()
+			return m, nil
+		case msg.Type == tea.KeyTab:
+			if m.Grep.Input.Focused() {
+				m.Grep.Input.Blur()
+				return m, nil
+			}
+			return m, m.Grep.Input.Focus()
+		case msg.String() == "alt+r":
+			m.Grep.Options.Regex = !m.Grep.Options.Regex
+			return m, nil
+		case msg.String() == "alt+c":
+			m.Grep.Options.CaseSensitive = !m.Grep.Options.CaseSensitive
+			return m, nil
+		case msg.String() == "alt+w":
+			m.Grep.Options.WholeWord = !m.Grep.Options.WholeWord
+			return m, nil
+		case msg.Type == tea.KeyEnter && m.Grep.Input.Focused():
+			results, err := m.Editor.GrepProject(m.Grep.Input.Value(), m.Grep.Options)
+			if err != nil {
+				m.ErrorMsg = fmt.Sprintf("Invalid pattern: %v", err)
+				return m, nil
+			}
+			m.ErrorMsg = ""
+			return m, m.Grep.Start(results)
+		case msg.Type == tea.KeyEnter:
+			hit, ok := m.Grep.Selected()
+			if !ok {
+				return m, nil
+			}
+			m.closeGrep()
+			m.Editor.OpenAt(hit.Path, hit.Line, hit.Column)
+			m.Viewport.SetContent(m.Editor.RenderContent())
+			return m, nil
+		}
+		if !m.Grep.Input.Focused() {
+			var cmd tea.Cmd
+			*m.Grep.List, cmd = m.Grep.List.Update(msg)
+			return m, cmd
+		}
+	}
+
+	var cmd tea.Cmd
+	m.Grep.Input, cmd = m.Grep.Input.Update(msg)
+	return m, cmd
+}
+
+func (m *UIModel) openHelp() {
+	m.ShowHelp = true
+	m.Help.SetSize(m.width, m.height)
+	m.Help.SetKeymap(m.Keymap)
+}
+
+// updateHelp scrolls the key binding overlay until esc or the help key
+// closes it.
+func (m *UIModel) updateHelp(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case editor.RemoteChange:
+		return m, waitForActivity(m.Editor.Update)
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
+		m.Help.SetSize(msg.Width, msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyEsc || m.Keymap.Command(msg.String()) == "help" {
+			m.ShowHelp = false
+			m.Viewport.SetContent(m.Editor.RenderContent())
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.Help, cmd = m.Help.Update(msg)
+	return m, cmd
+}
+
+func (m *UIModel) openPalette() tea.Cmd {
+	m.ShowPalette = true
+	m.Palette.SetSize(m.width, m.height)
+	return m.Palette.Open(m.Keymap)
+}
+
+// updatePalette filters the command palette as the user types. Enter runs
+// the selected command the same way its key does.
+func (m *UIModel) updatePalette(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case editor.RemoteChange:
+		return m, waitForActivity(m.Editor.Update)
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
+		m.Palette.SetSize(msg.Width, msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.ShowPalette = false
+			m.Palette.Close()
+			return m, nil
+		case tea.KeyEnter:
+			name, ok := m.Palette.Selected()
+			if !ok {
+				return m, nil
+			}
+			m.ShowPalette = false
+			m.Palette.Close()
+			cmd := m.runCommand(name)
+			m.Viewport.SetContent(m.Editor.RenderContent())
+			return m, cmd
+		}
+	}
+
+	var cmd tea.Cmd
+	m.Palette, cmd = m.Palette.Update(msg)
+	return m, cmd
+}
+
 func waitForActivity(sub chan struct{}) tea.Cmd {
 	return func() tea.Msg {
 		return editor.RemoteChange(<-sub)
 	}
 }
 
+func (m *UIModel) openMenu(list string) {
+	m.ShowMenu = true
+	m.Menu.current = list
+	m.refreshMenu()
+}
+
+// refreshMenu fills the menu lists that depend on the session state.
+func (m *UIModel) refreshMenu() {
+	m.Menu.SetFollowUsers(m.Editor.RemoteUsers())
+	projectFiles := []string{}
+	if m.Editor.Network.IsHost {
+		// Open files may lie outside the project walk, offer them too.
+		projectFiles = m.Editor.ProjectFiles()
+		for _, path := range m.Editor.Buffers {
+			if !slices.Contains(projectFiles, path) {
+				projectFiles = append(projectFiles, path)
+			}
+		}
+		sort.Strings(projectFiles)
+	}
+	m.Menu.SetDocuments(m.Editor.SharedFiles(), projectFiles, m.Editor.Network.IsHost)
+	m.Menu.SetBuffers(m.Editor.Buffers, m.Editor.Document, m.Editor.BufferModified)
+}
+
 func (m *UIModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	m.Menu, cmd = m.Menu.Update(msg, m.Editor.Network)
 
 	switch msg := msg.(type) {
+	case editor.RemoteChange:
+		return m, tea.Batch(cmd, waitForActivity(m.Editor.Update))
 	case tea.KeyMsg:
 		if msg.String() == "esc" {
 			if m.Menu.current == "main" {
@@ -128,15 +837,13 @@ func (m *UIModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case MenuMsg:
 		switch msg.Action {
-		case SaveAction:
-			m.saveFile()
+		case CommandAction:
 			m.ShowMenu = false
+			cmd := m.runCommand(msg.Data)
+			m.Viewport.SetContent(m.Editor.RenderContent())
+			return m, cmd
 		case QuitAction:
-			if m.UnsavedChanges {
-				fmt.Println("Warning: You have unsaved changes!")
-			}
-			m.Editor.Stop()
-			return m, tea.Quit
+			return m.quit()
 		case JoinSessionAction:
 			if msg.Data != "Back to Main Menu" && msg.Data != "Back to Editor" && msg.Data != "Quit" {
 				m.ShowMenu = false
@@ -145,11 +852,25 @@ func (m *UIModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Viewport.SetContent(m.Editor.RenderContent())
 					break
 				}
+				if modified := m.Editor.ModifiedBuffers(); len(modified) > 0 {
+					// Joining replaces the open files with the host's.
+					m.Editor.Error = "Save " + strings.Join(modified, ", ") + " before joining a session"
+					m.Viewport.SetContent(m.Editor.RenderContent())
+					break
+				}
 
+				// The host's files replace ours in every pane.
+				m.onlyPane()
+				err := m.Editor.JoinSession(msg.Data)
+				if err != nil {
+					m.Editor.Error = err.Error()
+					m.Viewport.SetContent(m.Editor.RenderContent())
+					break
+				}
 				go m.Editor.HandleConnections()
-				*m.Editor.RGA = m.Editor.Network.JoinSession(msg.Data)
 				m.Viewport.SetContent(m.Editor.RenderContent())
 				m.Editor.SendCursorUpdate()
+				m.Editor.SendViewportUpdate()
 			}
 		case CreatePublicSessionAction:
 			fmt.Println("Creating public session...")
@@ -161,6 +882,7 @@ func (m *UIModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 
+			m.Editor.StartHosting()
 			go m.Editor.HandleConnections()
 			go m.Editor.Network.BroadcastSession(m.Editor.RGA)
 			m.ShowMenu = false
@@ -168,6 +890,33 @@ func (m *UIModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case CreatePrivateSessionAction:
 			fmt.Println("Creating private session...")
 			m.ShowMenu = false
+		case KickAction, KickBanIDAction, KickBanIPAction:
+			ban := network.NoBan
+			if msg.Action == KickBanIDAction {
+				ban = network.BanID
+			} else if msg.Action == KickBanIPAction {
+				ban = network.BanIP
+			}
+			m.Editor.KickParticipant(msg.Data, ban)
+			m.Menu.current = "participants"
+			m.ShowMenu = false
+			m.Viewport.SetContent(m.Editor.RenderContent())
+		case FollowAction:
+			m.Editor.Follow(msg.Data)
+			m.ShowMenu = false
+			m.Viewport.SetContent(m.Editor.RenderContent())
+		case OpenDocumentAction:
+			m.Editor.OpenDocument(msg.Data)
+			m.ShowMenu = false
+			m.Viewport.SetContent(m.Editor.RenderContent())
+		case ToggleShareAction:
+			m.Editor.ToggleShared(msg.Data)
+			m.refreshMenu()
+			return m, nil
+		case StopFollowingAction:
+			m.Editor.StopFollowing()
+			m.ShowMenu = false
+			m.Viewport.SetContent(m.Editor.RenderContent())
 		case BackToEditorAction:
 			m.ShowMenu = false
 		}
@@ -181,40 +930,209 @@ func (m *UIModel) View() string {
 	if m.ShowMenu {
 		return m.Theme.RenderMenuTitle("Menu") + "\n" + m.Menu.View()
 	}
+	if m.ShowHelp {
+		return m.Help.View()
+	}
+	if m.ShowPalette {
+		return m.Palette.View()
+	}
+	if m.ShowGrep {
+		view := m.Grep.View()
+		if m.ErrorMsg != "" {
+			view = m.Theme.RenderError(m.ErrorMsg) + "\n" + view
+		}
+		return view
+	}
 
 	headerContent := m.Editor.FilePath
-	if m.UnsavedChanges {
+	if m.Editor.Modified() {
 		headerContent += " [Unsaved Changes]"
 	}
+	if len(m.Editor.Buffers) > 1 {
+		headerContent = m.tabs() + "  " + headerContent
+	}
+	if unread := m.Editor.UnreadChat(); unread > 0 && !m.ShowChat {
+		headerContent += fmt.Sprintf(" [Chat: %d unread]", unread)
+	}
 	header := m.Theme.RenderHeader(headerContent)
 
 	content := m.Viewport.View()
+	if m.Panes.Count() > 1 {
+		content = m.Panes.render(m.Panes.root, content, m.Editor, m.Theme.RenderPaneSeparator)
+	}
+	if m.ShowFiles {
+		content = m.Files.Join(content, m.Editor.FileStatus)
+	}
+	if m.ShowChat {
+		content = m.Chat.Join(content, m.Editor.ChatHistory())
+	}
 
-	footerContent := "Press ESC for menu"
+	footerContent := fmt.Sprintf("Press %s for menu, %s for key bindings", m.Keymap.Help("open-menu"), m.Keymap.Help("help"))
 	if m.ErrorMsg != "" {
 		footerContent = m.Theme.RenderError(m.ErrorMsg)
 	}
+	if vim := m.InputHandler.Vim; vim != nil {
+		footerContent = vim.Status()
+		if m.ErrorMsg != "" && vim.Mode != editor.VimCommandLine {
+			footerContent += "  " + m.Theme.RenderError(m.ErrorMsg)
+		}
+	}
+	if m.pendingKeys != nil {
+		footerContent = strings.Join(m.pendingKeys, " ") + "-"
+	}
+	if m.ShowSearch {
+		matches, current := m.Editor.MatchCount()
+		footerContent = m.Search.View(matches, current, m.Editor.Search.Err)
+	}
+	if m.ShowPrompt {
+		footerContent = m.Prompt.View()
+	}
 	footer := m.Theme.RenderFooter(footerContent)
 
 	return header + "\n" + content + "\n" + footer
 }
 
-func (m *UIModel) saveFile() {
+// closeBuffer closes the tab of the open file, panes that showed it move
+// on with the editor.
+func (m *UIModel) closeBuffer() {
+	closed := m.Editor.Document
+	m.Editor.CloseBuffer()
+	if m.Editor.Document != closed {
+		m.forgetDocument(closed)
+	}
+}
+
+// tabs lists the open files, the active one in brackets and modified ones
+// with a star.
+func (m *UIModel) tabs() string {
+	tabs := make([]string, len(m.Editor.Buffers))
+	for i, path := range m.Editor.Buffers {
+		tab := path
+		if m.Editor.BufferModified(path) {
+			tab += "*"
+		}
+		if path == m.Editor.Document {
+			tab = "[" + tab + "]"
+		}
+		tabs[i] = tab
+	}
+	return strings.Join(tabs, " ")
+}
+
+func (m *UIModel) saveDocument(path string) {
 	if m.Editor.Network.CurrentSession != "" && !m.Editor.Network.IsHost {
+		// Guests cannot write the host's file, ask the host to do it.
+		m.Editor.RequestHostSave()
+		m.Viewport.SetContent(m.Editor.RenderContent())
 		return
 	}
 
-	content := m.Editor.RenderDocumentWithoutLineNumbers()
-	err := os.WriteFile(m.Editor.FilePath, []byte(content), 0644)
-	if err != nil {
+	m.saved(path, m.Editor.Save(path))
+	m.Viewport.SetContent(m.Editor.RenderContent())
+}
+
+// saveRequested saves a file a guest asked us to save. The guest hears
+// back whether it was saved, also after we were asked to overwrite it.
+func (m *UIModel) saveRequested(request editor.SaveRequest) {
+	err := m.Editor.Save(request.Document)
+	if errors.Is(err, editor.ErrChangedOnDisk) {
+		m.overwriting = &request
+	} else if err != nil {
+		m.Editor.DeclineSave(request)
+	}
+	m.saved(request.Document, err)
+	m.Viewport.SetContent(m.Editor.RenderContent())
+}
+
+// saved reports how a save went. A file another program changed is only
+// overwritten once the user confirms.
+func (m *UIModel) saved(path string, err error) {
+	switch {
+	case errors.Is(err, editor.ErrChangedOnDisk):
+		m.confirmOverwrite(path)
+	case err != nil:
 		m.ErrorMsg = fmt.Sprintf("Error saving file: %v", err)
-	} else {
-		m.UnsavedChanges = false
+	default:
 		m.ErrorMsg = "File saved successfully"
+		m.Editor.NotifySaved(path)
+	}
+}
+
+func (m *UIModel) confirmOverwrite(path string) {
+	title := path + " changed on disk since it was loaded. Overwrite it?"
+	m.openPrompt(NewConfirmPrompt(OverwritePrompt, title, path, m.Theme))
+}
+
+func (m *UIModel) saveAs(file string, overwrite bool) {
+	file = strings.TrimSpace(file)
+	err := m.Editor.SaveAs(file, overwrite)
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		m.openPrompt(NewConfirmPrompt(ReplaceFilePrompt, file+" already exists. Replace it?", file, m.Theme))
+	case err != nil:
+		m.ErrorMsg = fmt.Sprintf("Error saving file: %v", err)
+	default:
+		m.ErrorMsg = "Saved as " + m.Editor.FilePath
+		m.Editor.NotifySaved(m.Editor.Document)
+	}
+	if m.ShowFiles {
+		m.refreshFiles()
 	}
 	m.Viewport.SetContent(m.Editor.RenderContent())
 }
 
+func (m *UIModel) saveLocalCopy(path string, overwrite bool) {
+	err := m.Editor.SaveLocalCopy(path, overwrite)
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		m.openPrompt(NewConfirmPrompt(ReplaceCopyPrompt, path+" already exists. Replace it?", path, m.Theme))
+	case err != nil:
+		m.ErrorMsg = fmt.Sprintf("Error saving copy: %v", err)
+	default:
+		m.ErrorMsg = "Saved a local copy to " + path
+	}
+}
+
+// saveAll writes every file with unsaved changes, including those guests
+// edited in the background.
+func (m *UIModel) saveAll() {
+	if m.Editor.Network.CurrentSession != "" && !m.Editor.Network.IsHost {
+		m.Editor.RequestHostSave()
+		return
+	}
+	saved := 0
+	failed := []string{}
+	m.conflicts = nil
+	for _, path := range m.Editor.ModifiedBuffers() {
+		err := m.Editor.Save(path)
+		switch {
+		case errors.Is(err, editor.ErrChangedOnDisk):
+			m.conflicts = append(m.conflicts, path)
+		case err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %v", path, err))
+		default:
+			m.Editor.NotifySaved(path)
+			saved++
+		}
+	}
+	m.ErrorMsg = fmt.Sprintf("Saved %d files", saved)
+	if len(failed) > 0 {
+		m.ErrorMsg += ". Error saving " + strings.Join(failed, ", ")
+	}
+	m.confirmNextConflict()
+}
+
+// confirmNextConflict asks whether to overwrite the next file Save All found
+// changed on disk.
+func (m *UIModel) confirmNextConflict() {
+	if len(m.conflicts) == 0 || m.ShowPrompt {
+		return
+	}
+	path := m.conflicts[0]
+	m.conflicts = m.conflicts[1:]
+	m.confirmOverwrite(path)
+}
+
 func generateSiteID() string {
 	return fmt.Sprintf("site-%d", time.Now().UnixNano())
 }